@@ -0,0 +1,38 @@
+// Command tune-argon2 measures Argon2id on this machine and prints the
+// ARGON2_* settings that make one password hash take about -target.
+//
+//	go run ./cmd/tune-argon2 -target 250ms -memory 65536
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/grainme/Chirpy/internal/auth"
+)
+
+func main() {
+	target := flag.Duration("target", 250*time.Millisecond, "how long one hash should take")
+	memory := flag.Uint("memory", 64*1024, "memory per hash in KiB")
+	parallelism := flag.Uint("parallelism", uint(runtime.NumCPU()), "threads per hash")
+	flag.Parse()
+
+	base := *argon2id.DefaultParams
+	base.Memory = uint32(*memory)
+	base.Parallelism = uint8(*parallelism)
+	if _, err := auth.NewPasswordHasher(base); err != nil {
+		log.Fatal(err)
+	}
+
+	params, took, err := auth.TunePasswordParams(base, *target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("# one hash took %v\n", took.Round(time.Millisecond))
+	fmt.Printf("ARGON2_MEMORY=%d\nARGON2_ITERATIONS=%d\nARGON2_PARALLELISM=%d\n",
+		params.Memory, params.Iterations, params.Parallelism)
+}
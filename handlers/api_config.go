@@ -1,19 +1,54 @@
 package handlers
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/billing"
 	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entitlements"
+	"github.com/grainme/Chirpy/internal/lockout"
+	"github.com/grainme/Chirpy/internal/mail"
+	"github.com/grainme/Chirpy/internal/moderation"
+	"github.com/grainme/Chirpy/internal/passwordpolicy"
 )
 
 type ApiConfig struct {
 	FileServerHits atomic.Int32
 	Db             *database.Queries
+	DbConn         *sql.DB
 	Platform       string
-	JWTSecretToken string
-	PolkaKey       string
+	JWTKeys        *auth.Keyring
+	Passwords      *auth.PasswordHasher
+	PasswordPolicy passwordpolicy.Policy
+	AdminKey       string
+	ChirpFilter    moderation.Filter
+	WordList       *moderation.WordList
+	Mailer         mail.Mailer
+	BaseURL        string // where users reach Chirpy, for links in emails
+	LoginLimiter   *lockout.Limiter
+	Entitlements   *entitlements.Service
+
+	// RequireVerifiedEmail stops users posting chirps until they've
+	// verified their email.
+	RequireVerifiedEmail bool
+
+	// BillingProviders are the payment providers whose webhooks are
+	// accepted, by name.
+	BillingProviders map[string]billing.Provider
+
+	// SubscriptionGracePeriod is added to a subscription's
+	// current_period_end to get its grace_period_end.
+	SubscriptionGracePeriod time.Duration
 }
 
 func (cfg *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
@@ -34,3 +69,92 @@ func (cfg *ApiConfig) HandlerMetrics(w http.ResponseWriter, r *http.Request) {
 </body>
 </html>`, cfg.FileServerHits.Load())
 }
+
+// withTx runs fn with queries bound to a single transaction, committing
+// if fn returns nil and rolling back otherwise.
+func (cfg *ApiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.DbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(cfg.Db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// viewerID returns the caller's user ID when the request carries a valid JWT.
+// Public endpoints use it to personalise responses without requiring auth.
+func (cfg *ApiConfig) viewerID(r *http.Request) uuid.NullUUID {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := cfg.JWTKeys.ValidateJWT(bearerToken)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+// authenticate returns the user the request's access token belongs to. When
+// there isn't a valid one it writes a 401 saying what was wrong with it and
+// returns false.
+func (cfg *ApiConfig) authenticate(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondWithError(w, http.StatusUnauthorized, "Bearer token is missing")
+		return uuid.Nil, false
+	}
+
+	userID, err := cfg.JWTKeys.ValidateJWT(bearerToken)
+	if err != nil {
+		log.Printf("%v", err)
+		msg := tokenErrorMessage(err)
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"invalid_token\", error_description=%q", msg))
+		respondWithError(w, http.StatusUnauthorized, msg)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// tokenErrorMessage tells the client why its access token was refused, so it
+// knows whether refreshing will help. Signature problems all get the same
+// message.
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "Token has expired"
+	case errors.Is(err, auth.ErrTokenNotYetValid):
+		return "Token is not valid yet"
+	case errors.Is(err, auth.ErrTokenWrongIssuer), errors.Is(err, auth.ErrTokenWrongAudience):
+		return "Token was not issued for this service"
+	case errors.Is(err, auth.ErrTokenMalformed):
+		return "Token is malformed"
+	default:
+		return "Token is invalid"
+	}
+}
+
+// authorizeAdmin checks the "Authorization: ApiKey <key>" header against
+// AdminKey and writes the error response itself when it doesn't match.
+// Admin endpoints stay closed when no AdminKey is configured.
+func (cfg *ApiConfig) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.AdminKey == "" {
+		respondWithError(w, http.StatusForbidden, "Admin API is disabled")
+		return false
+	}
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Api key not found")
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.AdminKey)) != 1 {
+		respondWithError(w, http.StatusUnauthorized, "Api key mismatch")
+		return false
+	}
+	return true
+}
@@ -1,30 +1,99 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"slices"
-	"sort"
-	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/grainme/Chirpy/internal/auth"
 	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entitlements"
 )
 
 const (
-	maxBodyLength = 140
+	// tombstoneBody stands in for a deleted chirp that still has replies.
+	tombstoneBody = "[deleted]"
+)
+
+var (
+	errParentChirpNotFound = errors.New("parent chirp not found")
+	errChirpRateLimited    = errors.New("too many chirps")
 )
 
 type chirpsParams struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Body           string     `json:"body"`
+	UserID         uuid.UUID  `json:"user_id"`
+	AuthorVerified bool       `json:"author_verified"`
+	ParentID       *uuid.UUID `json:"parent_id,omitempty"`
+	Deleted        bool       `json:"deleted,omitempty"`
+	LikeCount      int64      `json:"like_count"`
+	LikedByMe      *bool      `json:"liked_by_me,omitempty"`
+	Mentions       []mention  `json:"mentions"`
+}
+
+func chirpResponse(chirp database.Chirp) chirpsParams {
+	res := chirpsParams{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+	if chirp.ParentID.Valid {
+		res.ParentID = &chirp.ParentID.UUID
+	}
+	if chirp.DeletedAt.Valid {
+		res.Body = tombstoneBody
+		res.UserID = uuid.Nil
+		res.Deleted = true
+	}
+	return res
+}
+
+// withLikes fills in the like fields; liked_by_me is only reported to
+// authenticated viewers.
+func (c chirpsParams) withLikes(likeCount int64, likedByMe bool, viewerID uuid.NullUUID) chirpsParams {
+	c.LikeCount = likeCount
+	if viewerID.Valid {
+		c.LikedByMe = &likedByMe
+	}
+	return c
+}
+
+// chirpView builds the full payload of a single chirp, looking up its like
+// stats, mentions and badge. List endpoints get those in bulk instead.
+func (cfg *ApiConfig) chirpView(ctx context.Context, chirp database.Chirp, viewerID uuid.NullUUID) (chirpsParams, error) {
+	stats, err := cfg.Db.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewerID,
+		ChirpID:  chirp.ID,
+	})
+	if err != nil {
+		return chirpsParams{}, err
+	}
+
+	res := []chirpsParams{chirpResponse(chirp).withLikes(stats.LikeCount, stats.LikedByMe, viewerID)}
+	if err := cfg.attachDetails(ctx, res); err != nil {
+		return chirpsParams{}, err
+	}
+	return res[0], nil
+}
+
+// attachDetails fills in the mentions and author badges of chirps, with a
+// query for each rather than one per chirp.
+func (cfg *ApiConfig) attachDetails(ctx context.Context, chirps []chirpsParams) error {
+	if err := cfg.attachMentions(ctx, chirps); err != nil {
+		return err
+	}
+	return cfg.attachBadges(ctx, chirps)
 }
 
 func (cfg *ApiConfig) HandlerGetChirpById(w http.ResponseWriter, r *http.Request) {
@@ -39,26 +108,23 @@ func (cfg *ApiConfig) HandlerGetChirpById(w http.ResponseWriter, r *http.Request
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("%s", err))
 		return
 	}
-	respondWithJson(w, http.StatusOK, chirpsParams{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
-}
-
-func (cfg *ApiConfig) HandlerDeleteChirpById(w http.ResponseWriter, r *http.Request) {
-	header := strings.Fields(r.Header.Get("Authorization"))
-	if len(header) < 2 {
-		respondWithError(w, http.StatusUnauthorized, "Bearer token is missing")
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
 		return
 	}
 
-	bearerToken := header[1]
-	userID, err := auth.ValidateJWT(bearerToken, cfg.JWTSecretToken)
+	res, err := cfg.chirpView(r.Context(), chirp, cfg.viewerID(r))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("%v", err))
+		log.Printf("failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) HandlerDeleteChirpById(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
 		return
 	}
 
@@ -73,83 +139,156 @@ func (cfg *ApiConfig) HandlerDeleteChirpById(w http.ResponseWriter, r *http.Requ
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Couldn't get chirp: %v", err))
 		return
 	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp")
+		return
+	}
 
 	if chirp.UserID != userID {
 		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can't delete this chirp: %v", err))
 		return
 	}
 
-	err = cfg.Db.DeleteChirpById(r.Context(), chirpID)
+	// a chirp with replies becomes a "[deleted]" tombstone so the thread
+	// below it stays reachable, anything else is removed outright. The lock
+	// stops a reply arriving between the check and the delete.
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		locked, err := q.GetChirpByIdForUpdate(r.Context(), chirpID)
+		if err != nil {
+			return err
+		}
+		if locked.DeletedAt.Valid {
+			return sql.ErrNoRows
+		}
+		hasReplies, err := q.ChirpHasReplies(r.Context(), chirpID)
+		if err != nil {
+			return err
+		}
+		if hasReplies {
+			return q.TombstoneChirp(r.Context(), chirpID)
+		}
+		return q.DeleteChirpById(r.Context(), chirpID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp")
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, fmt.Sprintf("%s", err))
+		log.Printf("failed to delete chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp")
 		return
 	}
 	respondWithJson(w, http.StatusNoContent, nil)
 }
 
+type chirpsPage struct {
+	Chirps     []chirpsParams `json:"chirps"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
 func (cfg *ApiConfig) HandlerGetAllChirps(w http.ResponseWriter, r *http.Request) {
-	var chirps []database.Chirp
+	var chirps []database.ListChirpsAscRow
 	var err error
 
 	author_id := r.URL.Query().Get("author_id")
 	sort_type := r.URL.Query().Get("sort")
 
+	pageSize, err := parsePageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var authorID uuid.NullUUID
 	if author_id != "" {
 		userId, err := uuid.Parse(author_id)
 		if err != nil {
-			log.Printf("Failed to fetch all chirps: %v", err)
-			respondWithError(w, http.StatusInternalServerError, "Could not parse userID into UUID format")
-			return
-		}
-		chirps, err = cfg.Db.GetChirpByUserId(r.Context(), userId)
-	} else {
-		chirps, err = cfg.Db.GetAllChirps(r.Context())
-		if err != nil {
-			log.Printf("Failed to fetch all chirps: %v", err)
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			respondWithError(w, http.StatusBadRequest, "Could not parse author_id into UUID format")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: userId, Valid: true}
 	}
 
-	chirpsMapped := make([]chirpsParams, 0, len(chirps))
-	for _, chirp := range chirps {
-		chirpsMapped = append(chirpsMapped, chirpsParams{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	cursorCreatedAt, cursorID, err := cursorFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
+	viewerID := cfg.viewerID(r)
+
+	// one extra row tells us whether there's a next page without a COUNT(*)
 	if sort_type == "desc" {
-		sort.Slice(chirpsMapped, func(i, j int) bool {
-			return chirpsMapped[i].CreatedAt.After(chirpsMapped[j].CreatedAt)
+		var rows []database.ListChirpsDescRow
+		rows, err = cfg.Db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			ViewerID:        viewerID,
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        pageSize + 1,
 		})
+		for _, row := range rows {
+			chirps = append(chirps, database.ListChirpsAscRow(row))
+		}
 	} else {
-		sort.Slice(chirpsMapped, func(i, j int) bool {
-			return chirpsMapped[i].CreatedAt.Before(chirpsMapped[j].CreatedAt)
+		chirps, err = cfg.Db.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			ViewerID:        viewerID,
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			PageSize:        pageSize + 1,
 		})
 	}
-	respondWithJson(w, http.StatusOK, chirpsMapped)
+	if err != nil {
+		log.Printf("Failed to fetch chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirps")
+		return
+	}
+
+	var page chirpsPage
+	if len(chirps) > int(pageSize) {
+		chirps = chirps[:pageSize]
+		last := chirps[len(chirps)-1].Chirp
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	page.Chirps = make([]chirpsParams, 0, len(chirps))
+	for _, row := range chirps {
+		page.Chirps = append(page.Chirps, chirpResponse(row.Chirp).withLikes(row.LikeCount, row.LikedByMe, viewerID))
+	}
+	if err := cfg.attachDetails(r.Context(), page.Chirps); err != nil {
+		log.Printf("Failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirps")
+		return
+	}
+	respondWithJson(w, http.StatusOK, page)
 }
 
 func (cfg *ApiConfig) HandlerValidateAndSaveChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body     string     `json:"body"`
+		ParentID *uuid.UUID `json:"parent_id"`
 	}
 
-	bearerToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("failed to get Bearer token: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(bearerToken, cfg.JWTSecretToken)
-	if err != nil {
-		log.Printf("%v", err)
-		respondWithError(w, http.StatusUnauthorized, "Unauthorized to proceed with the request")
+	if cfg.RequireVerifiedEmail {
+		user, err := cfg.Db.FindUserById(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user")
+			return
+		}
+		if !user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusForbidden, "Verify your email address before posting")
+			return
+		}
+	}
+
+	perks, ok := cfg.perks(w, r, userID)
+	if !ok {
 		return
 	}
 
@@ -162,44 +301,210 @@ func (cfg *ApiConfig) HandlerValidateAndSaveChirp(w http.ResponseWriter, r *http
 		return
 	}
 
-	if params.Body == "" {
-		respondWithError(w, http.StatusBadRequest, "Chirp body cannot be empty")
-	} else if len(params.Body) > maxBodyLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
-	} else {
-		// THIS IS A VALID CHIRP
-		// filtering logic based on requirements
-		cleanedBody := make([]string, 0)
-		for word := range strings.FieldsSeq(params.Body) {
-			redFlag := []string{"kerfuffle", "sharbert", "fornax"}
-			if slices.Contains(redFlag, strings.ToLower(word)) {
-				cleanedBody = append(cleanedBody, "****")
-			} else {
-				cleanedBody = append(cleanedBody, word)
+	cleanedBody, err := cfg.cleanChirpBody(params.Body, perks)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var (
+		chirp database.Chirp
+		wait  time.Duration
+	)
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		wait, err = takeChirpSlot(r.Context(), q, userID, perks)
+		if err != nil {
+			return err
+		}
+		if wait > 0 {
+			return errChirpRateLimited
+		}
+
+		// the parent stays locked until the reply is in, so it can't be
+		// deleted out from under it
+		var parentID uuid.NullUUID
+		if params.ParentID != nil {
+			parent, err := q.GetChirpByIdForShare(r.Context(), *params.ParentID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && parent.DeletedAt.Valid) {
+				return errParentChirpNotFound
+			}
+			if err != nil {
+				return err
 			}
+			parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+
+		chirp, err = q.CreateChirp(r.Context(), database.CreateChirpParams{
+			ID:       uuid.New(),
+			Body:     cleanedBody,
+			UserID:   userID,
+			ParentID: parentID,
+		})
+		if err != nil {
+			return err
 		}
+		if err := syncChirpHashtags(r.Context(), q, chirp); err != nil {
+			return err
+		}
+		return syncChirpMentions(r.Context(), q, chirp)
+	})
+	if errors.Is(err, errChirpRateLimited) {
+		respondWithChirpRateLimit(w, wait)
+		return
+	}
+	if errors.Is(err, errParentChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find parent chirp")
+		return
+	}
+	if err != nil {
+		log.Printf("failed to create chirp: %v", err)
+		log.Printf("[debug] Params: %v", params)
+		log.Printf("[debug] Database chirp: %v", chirp)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+		return
+	}
+
+	res, err := cfg.chirpView(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		log.Printf("failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, res)
+}
+
+func (cfg *ApiConfig) HandlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	var params parameters
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		log.Printf("JSON Decode error: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can't edit this chirp")
+		return
+	}
+
+	perks, ok := cfg.perks(w, r, userID)
+	if !ok {
+		return
+	}
+	if !perks.EditChirps {
+		respondWithError(w, http.StatusForbidden, "Editing chirps is a Chirpy Red perk")
+		return
+	}
 
-		chirp, err := cfg.Db.CreateChirp(r.Context(), database.CreateChirpParams{
-			ID:     uuid.New(),
-			Body:   strings.Join(cleanedBody, " "),
-			UserID: userID,
+	cleanedBody, err := cfg.cleanChirpBody(params.Body, perks)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// nothing changed, don't record an empty revision
+	if cleanedBody != chirp.Body {
+		err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+			var err error
+			chirp, err = q.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+				RevisionID: uuid.New(),
+				ID:         chirpID,
+				Body:       cleanedBody,
+			})
+			if err != nil {
+				return err
+			}
+			if err := syncChirpHashtags(r.Context(), q, chirp); err != nil {
+				return err
+			}
+			return syncChirpMentions(r.Context(), q, chirp)
 		})
 		if err != nil {
-			log.Printf("failed to create chirp: %v", err)
-			log.Printf("[debug] Params: %v", params)
-			log.Printf("[debug] Database chirp: %v", chirp)
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("%s", err))
+			log.Printf("failed to update chirp: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp")
 			return
 		}
+	}
+
+	res, err := cfg.chirpView(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		log.Printf("failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func (cfg *ApiConfig) HandlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	type revision struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		ChirpID   uuid.UUID `json:"chirp_id"`
+		Body      string    `json:"body"`
+	}
 
-		respondWithJson(w, http.StatusCreated, chirpsParams{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	revisions, err := cfg.Db.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("failed to fetch chirp revisions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp revisions")
+		return
+	}
+
+	revisionsMapped := make([]revision, 0, len(revisions))
+	for _, rev := range revisions {
+		revisionsMapped = append(revisionsMapped, revision{
+			ID:        rev.ID,
+			CreatedAt: rev.CreatedAt,
+			ChirpID:   rev.ChirpID,
+			Body:      rev.Body,
 		})
 	}
+	respondWithJson(w, http.StatusOK, revisionsMapped)
+}
+
+// cleanChirpBody enforces the author's length limit and masks profane
+// words. Both creating and editing a chirp go through it.
+func (cfg *ApiConfig) cleanChirpBody(body string, perks entitlements.Perks) (string, error) {
+	if body == "" {
+		return "", errors.New("Chirp body cannot be empty")
+	}
+	if utf8.RuneCountInString(body) > perks.MaxChirpLength {
+		return "", fmt.Errorf("Chirp is too long, the limit is %d characters", perks.MaxChirpLength)
+	}
+	return cfg.ChirpFilter.Clean(body), nil
 }
 
 func respondWithJson(w http.ResponseWriter, code int, payload any) {
@@ -231,3 +536,23 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 	w.WriteHeader(code)
 	w.Write(data)
 }
+
+// fieldError says what's wrong with one field of a request body, with a
+// code clients can switch on and a message they can show.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// respondWithFieldErrors is respondWithError plus the individual problems,
+// for forms that want to show them next to the right input.
+func respondWithFieldErrors(w http.ResponseWriter, code int, msg string, fields []fieldError) {
+	respondWithJson(w, code, struct {
+		Err    string       `json:"error"`
+		Fields []fieldError `json:"fields"`
+	}{
+		Err:    msg,
+		Fields: fields,
+	})
+}
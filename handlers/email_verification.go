@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/database"
+	chirpymail "github.com/grainme/Chirpy/internal/mail"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	maxEmailLength       = 254
+)
+
+var errVerificationTokenInvalid = errors.New("verification token is invalid, expired or superseded")
+
+// normalizeEmail trims email and checks it's a bare address
+// ("bob@example.com", not "Bob <bob@example.com>").
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", errors.New("Email cannot be empty")
+	}
+	if len(email) > maxEmailLength {
+		return "", fmt.Errorf("Email can be at most %d characters", maxEmailLength)
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", errors.New("Email is not a valid address")
+	}
+	return email, nil
+}
+
+// createEmailVerification stores a token proving userID owns email, and
+// returns the message to send it in. Callers send it once their
+// transaction has committed.
+func (cfg *ApiConfig) createEmailVerification(ctx context.Context, q *database.Queries, userID uuid.UUID, email string) (chirpymail.Message, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return chirpymail.Message{}, err
+	}
+	if err := q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	}); err != nil {
+		return chirpymail.Message{}, err
+	}
+
+	link := cfg.BaseURL + "/app/verify-email?token=" + url.QueryEscape(token)
+	return chirpymail.Message{
+		To:      email,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf("To confirm this is your email address on Chirpy, open this link "+
+			"within %d hours:\n\n%s\n\n"+
+			"If you didn't sign up or change your email on Chirpy, ignore this email.\n",
+			int(emailVerificationTTL.Hours()), link),
+	}, nil
+}
+
+// HandlerVerifyEmail confirms an address using the token from a verification
+// email. If it's the user's pending email, it becomes their email now.
+func (cfg *ApiConfig) HandlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	var user database.User
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		verification, err := q.ConsumeEmailVerificationToken(r.Context(), auth.HashToken(params.Token))
+		if errors.Is(err, sql.ErrNoRows) {
+			return errVerificationTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		// no rows if the user has since switched to, or asked for, another email
+		user, err = q.ConfirmEmail(r.Context(), database.ConfirmEmailParams{
+			Email: verification.Email,
+			ID:    verification.UserID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errVerificationTokenInvalid
+		}
+		return err
+	})
+	if errors.Is(err, errVerificationTokenInvalid) {
+		respondWithError(w, http.StatusBadRequest, "Verification link is invalid or has expired")
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already taken")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify email: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email")
+		return
+	}
+
+	res, err := cfg.userResponse(r.Context(), user)
+	if err != nil {
+		log.Printf("Could not fetch subscription: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
+
+// HandlerResendVerification sends a new verification email, to the pending
+// email if there is one and otherwise to the unverified current one.
+func (cfg *ApiConfig) HandlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.Db.FindUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+
+	email := user.PendingEmail.String
+	if !user.PendingEmail.Valid {
+		if user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "Email is already verified")
+			return
+		}
+		email = user.Email
+	}
+
+	msg, err := cfg.createEmailVerification(r.Context(), cfg.Db, userID, email)
+	if err != nil {
+		log.Printf("Could not store verification token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	cfg.sendMailAsync(msg)
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
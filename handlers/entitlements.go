@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entitlements"
+)
+
+// perks looks up what userID gets to do, writing a 500 and returning false
+// if it can't.
+func (cfg *ApiConfig) perks(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (entitlements.Perks, bool) {
+	perks, err := cfg.Entitlements.For(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to look up entitlements: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up your account")
+		return entitlements.Perks{}, false
+	}
+	return perks, true
+}
+
+// takeChirpSlot counts a post by userID against the rate perks allow, or,
+// if they've already posted as many chirps as that in the current window,
+// returns how long they must wait. It must run in the transaction that
+// creates the chirp: the user's posts are counted under a lock held until
+// it ends, so requests posting at once can't all see room for one more.
+func takeChirpSlot(ctx context.Context, q *database.Queries, userID uuid.UUID, perks entitlements.Perks) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-perks.ChirpRate.Per)
+	if err := q.LockChirpPosts(ctx, userID); err != nil {
+		return 0, err
+	}
+	if err := q.PruneChirpPosts(ctx, database.PruneChirpPostsParams{UserID: userID, Since: since}); err != nil {
+		return 0, err
+	}
+	stats, err := q.GetRecentChirpStats(ctx, database.GetRecentChirpStatsParams{UserID: userID, Since: since})
+	if err != nil {
+		return 0, err
+	}
+
+	if wait := perks.ChirpRate.RetryAfter(stats.ChirpCount, stats.Oldest, now); wait > 0 {
+		return wait, nil
+	}
+	return 0, q.RecordChirpPost(ctx, userID)
+}
+
+// respondWithChirpRateLimit tells the client to wait before posting again.
+func respondWithChirpRateLimit(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many chirps, try again later")
+}
+
+// attachBadges fills in AuthorVerified for every chirp in one lookup.
+func (cfg *ApiConfig) attachBadges(ctx context.Context, chirps []chirpsParams) error {
+	authors := make([]uuid.UUID, 0, len(chirps))
+	seen := make(map[uuid.UUID]bool)
+	for _, c := range chirps {
+		if !c.Deleted && !seen[c.UserID] {
+			seen[c.UserID] = true
+			authors = append(authors, c.UserID)
+		}
+	}
+
+	perks, err := cfg.Entitlements.ForUsers(ctx, authors)
+	if err != nil {
+		return err
+	}
+	for i := range chirps {
+		chirps[i].AuthorVerified = !chirps[i].Deleted && perks[chirps[i].UserID].VerifiedBadge
+	}
+	return nil
+}
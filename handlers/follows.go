@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) HandlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleFollow(w, r, true)
+}
+
+func (cfg *ApiConfig) HandlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleFollow(w, r, false)
+}
+
+// handleFollow makes the caller follow or unfollow {userID}. Both directions
+// are idempotent.
+func (cfg *ApiConfig) handleFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	followerID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if followeeID == followerID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself")
+		return
+	}
+
+	if _, err := cfg.Db.FindUserById(r.Context(), followeeID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+
+	if follow {
+		err = cfg.Db.FollowUser(r.Context(), database.FollowUserParams{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+		})
+	} else {
+		err = cfg.Db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+		})
+	}
+	if err != nil {
+		log.Printf("failed to update follow: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update follow")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+func (cfg *ApiConfig) HandlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	pageSize, err := parsePageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := cursorFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.Db.ListTimeline(r.Context(), database.ListTimelineParams{
+		FollowerID:      userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		log.Printf("Failed to fetch timeline: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch timeline")
+		return
+	}
+
+	var page chirpsPage
+	if len(chirps) > int(pageSize) {
+		chirps = chirps[:pageSize]
+		last := chirps[len(chirps)-1].Chirp
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+	page.Chirps = make([]chirpsParams, 0, len(chirps))
+	for _, row := range chirps {
+		page.Chirps = append(page.Chirps, chirpResponse(row.Chirp).withLikes(row.LikeCount, row.LikedByMe, viewerID))
+	}
+	if err := cfg.attachDetails(r.Context(), page.Chirps); err != nil {
+		log.Printf("Failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirps")
+		return
+	}
+	respondWithJson(w, http.StatusOK, page)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entities"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingTags   = 10
+	maxTrendingTags       = 50
+)
+
+// syncChirpHashtags makes the chirp's rows in chirp_hashtags match the tags
+// in its current body. Run it in the same transaction that wrote the body.
+func syncChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	tags := entities.Hashtags(chirp.Body)
+
+	if err := q.PruneChirpHashtags(ctx, database.PruneChirpHashtagsParams{
+		ChirpID: chirp.ID,
+		Keep:    tags,
+	}); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		hashtag, err := q.UpsertHashtag(ctx, database.UpsertHashtagParams{
+			ID:  uuid.New(),
+			Tag: tag,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) HandlerGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag, ok := entities.NormalizeHashtag(r.PathValue("tag"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+
+	pageSize, err := parsePageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := cursorFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	viewerID := cfg.viewerID(r)
+	chirps, err := cfg.Db.ListHashtagChirps(r.Context(), database.ListHashtagChirpsParams{
+		ViewerID:        viewerID,
+		Tag:             tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		log.Printf("Failed to fetch hashtag chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirps")
+		return
+	}
+
+	var page chirpsPage
+	if len(chirps) > int(pageSize) {
+		chirps = chirps[:pageSize]
+		last := chirps[len(chirps)-1].Chirp
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	page.Chirps = make([]chirpsParams, 0, len(chirps))
+	for _, row := range chirps {
+		page.Chirps = append(page.Chirps, chirpResponse(row.Chirp).withLikes(row.LikeCount, row.LikedByMe, viewerID))
+	}
+	if err := cfg.attachDetails(r.Context(), page.Chirps); err != nil {
+		log.Printf("Failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirps")
+		return
+	}
+	respondWithJson(w, http.StatusOK, page)
+}
+
+// HandlerTrendingHashtags ranks tags by how many chirps picked them up within
+// the last ?window= (a Go duration like "6h", default 24h, max 7 days).
+func (cfg *ApiConfig) HandlerTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	type trendingTag struct {
+		Tag  string `json:"tag"`
+		Uses int64  `json:"uses"`
+	}
+
+	window := defaultTrendingWindow
+	if wv := r.URL.Query().Get("window"); wv != "" {
+		var err error
+		window, err = time.ParseDuration(wv)
+		if err != nil || window < time.Minute || window > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, "window must be a duration between 1m and "+maxTrendingWindow.String())
+			return
+		}
+	}
+
+	maxTags := defaultTrendingTags
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		maxTags, err = strconv.Atoi(l)
+		if err != nil || maxTags < 1 || maxTags > maxTrendingTags {
+			respondWithError(w, http.StatusBadRequest, "limit must be a number between 1 and "+strconv.Itoa(maxTrendingTags))
+			return
+		}
+	}
+
+	rows, err := cfg.Db.TrendingHashtags(r.Context(), database.TrendingHashtagsParams{
+		WindowSeconds: int32(window.Seconds()),
+		MaxTags:       int32(maxTags),
+	})
+	if err != nil {
+		log.Printf("Failed to fetch trending hashtags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch trending hashtags")
+		return
+	}
+
+	trending := make([]trendingTag, 0, len(rows))
+	for _, row := range rows {
+		trending = append(trending, trendingTag{
+			Tag:  row.Tag,
+			Uses: row.Uses,
+		})
+	}
+	respondWithJson(w, http.StatusOK, trending)
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import "net/http"
+
+// HandlerJWKS publishes the public keys access tokens are signed with, so
+// other services can verify them without sharing a secret. Verifiers should
+// re-fetch it when they see a "kid" they don't know.
+func (cfg *ApiConfig) HandlerJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJson(w, http.StatusOK, cfg.JWTKeys.JWKS())
+}
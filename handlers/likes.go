@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) HandlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleLike(w, r, true)
+}
+
+func (cfg *ApiConfig) HandlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleLike(w, r, false)
+}
+
+// handleLike adds or removes the caller's like and responds with the chirp's
+// updated counts. Both directions are idempotent.
+func (cfg *ApiConfig) handleLike(w http.ResponseWriter, r *http.Request, like bool) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	chirp, err := cfg.Db.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	if like {
+		err = cfg.Db.LikeChirp(r.Context(), database.LikeChirpParams{
+			ChirpID: chirpID,
+			UserID:  userID,
+		})
+	} else {
+		err = cfg.Db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+			ChirpID: chirpID,
+			UserID:  userID,
+		})
+	}
+	if err != nil {
+		log.Printf("failed to update chirp like: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update like")
+		return
+	}
+
+	res, err := cfg.chirpView(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		log.Printf("failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp")
+		return
+	}
+	respondWithJson(w, http.StatusOK, res)
+}
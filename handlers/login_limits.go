@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+// loginAttemptRetention is how long the login_attempts audit log keeps an
+// attempt. Throttled attempts are logged too, so without a limit someone
+// locked out could still grow the table forever.
+const loginAttemptRetention = 90 * 24 * time.Hour
+
+// Outcomes recorded in the login_attempts audit log.
+const (
+	loginSucceeded        = "success"
+	loginFailed           = "failure"
+	loginThrottled        = "throttled"
+	loginChallenged       = "2fa_required"
+	loginSecondFactorFail = "2fa_failure"
+)
+
+// reserveLogin responds with 429 and returns false if the caller has to
+// wait before trying to log in to email again. Otherwise the attempt counts
+// as a failure until passLogin or succeedLogin takes it back. If the limiter
+// itself fails, the attempt is let through rather than locking everyone out.
+func (cfg *ApiConfig) reserveLogin(w http.ResponseWriter, r *http.Request, email string, userID uuid.NullUUID) bool {
+	wait, err := cfg.LoginLimiter.Attempt(r.Context(), email, clientIP(r))
+	if err != nil {
+		log.Printf("Failed to check login limit: %v", err)
+		return true
+	}
+	if wait == 0 {
+		return true
+	}
+
+	cfg.auditLogin(r, email, userID, loginThrottled)
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	return false
+}
+
+// failLogin audits a failed attempt. reserveLogin has already counted it
+// against both email and the client IP.
+func (cfg *ApiConfig) failLogin(r *http.Request, email string, userID uuid.NullUUID, outcome string) {
+	cfg.auditLogin(r, email, userID, outcome)
+}
+
+// passLogin takes back the attempt reserved for a right password when the
+// login still needs a second factor.
+func (cfg *ApiConfig) passLogin(r *http.Request, email string, userID uuid.UUID) {
+	cfg.auditLogin(r, email, uuid.NullUUID{UUID: userID, Valid: true}, loginChallenged)
+	if err := cfg.LoginLimiter.Pass(r.Context(), email, clientIP(r)); err != nil {
+		log.Printf("Failed to release login attempt: %v", err)
+	}
+}
+
+// succeedLogin clears email's failures once the user is fully logged in.
+func (cfg *ApiConfig) succeedLogin(r *http.Request, email string, userID uuid.UUID) {
+	cfg.auditLogin(r, email, uuid.NullUUID{UUID: userID, Valid: true}, loginSucceeded)
+	if err := cfg.LoginLimiter.Succeed(r.Context(), email, clientIP(r)); err != nil {
+		log.Printf("Failed to reset login limit: %v", err)
+	}
+}
+
+func (cfg *ApiConfig) auditLogin(r *http.Request, email string, userID uuid.NullUUID, outcome string) {
+	if err := cfg.Db.CreateLoginAttempt(r.Context(), database.CreateLoginAttemptParams{
+		Email:     truncate(email, maxEmailLength),
+		UserID:    userID,
+		IpAddress: clientIP(r),
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		Outcome:   outcome,
+	}); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// PruneLoginLimitsEvery drops expired lockout state, and audited attempts
+// older than loginAttemptRetention, every interval. It never returns, so
+// run it in its own goroutine.
+func (cfg *ApiConfig) PruneLoginLimitsEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := cfg.LoginLimiter.Prune(context.Background()); err != nil {
+			log.Printf("Failed to prune login limits: %v", err)
+		}
+		pruned, err := cfg.Db.PruneLoginAttempts(context.Background(), time.Now().Add(-loginAttemptRetention))
+		if err != nil {
+			log.Printf("Failed to prune login attempts: %v", err)
+			continue
+		}
+		if pruned > 0 {
+			log.Printf("Pruned %d old login attempts", pruned)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entities"
+)
+
+// mention is an @handle in a chirp body that resolved to a user.
+// Start and End are code point offsets into body, End exclusive.
+type mention struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Start  int       `json:"start"`
+	End    int       `json:"end"`
+}
+
+// syncChirpMentions resolves the @handles in the chirp's current body and
+// replaces its rows in chirp_mentions. Handles that don't belong to anyone
+// are ignored. Run it in the same transaction that wrote the body.
+func syncChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.ClearChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	handles := make([]string, 0)
+	for _, m := range entities.Mentions(chirp.Body) {
+		handles = append(handles, m.Handle)
+	}
+	if len(handles) == 0 {
+		return nil
+	}
+
+	users, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID: chirp.ID,
+			UserID:  user.ID,
+			Handle:  user.Handle.String,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachMentions fills in Mentions for every chirp in one query. Offsets come
+// from the body; chirp_mentions says which user each handle pointed to when
+// the chirp was written, so renaming a user doesn't re-target old mentions.
+func (cfg *ApiConfig) attachMentions(ctx context.Context, chirps []chirpsParams) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		if !c.Deleted {
+			ids = append(ids, c.ID)
+		}
+	}
+
+	resolved := make(map[uuid.UUID]map[string]uuid.UUID)
+	if len(ids) > 0 {
+		rows, err := cfg.Db.ListChirpMentions(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if resolved[row.ChirpID] == nil {
+				resolved[row.ChirpID] = make(map[string]uuid.UUID)
+			}
+			resolved[row.ChirpID][row.Handle] = row.UserID
+		}
+	}
+
+	for i := range chirps {
+		chirps[i].Mentions = make([]mention, 0)
+		if chirps[i].Deleted {
+			continue
+		}
+		for _, m := range entities.Mentions(chirps[i].Body) {
+			userID, ok := resolved[chirps[i].ID][m.Handle]
+			if !ok {
+				continue
+			}
+			chirps[i].Mentions = append(chirps[i].Mentions, mention{
+				UserID: userID,
+				Handle: m.Handle,
+				Start:  m.Start,
+				End:    m.End,
+			})
+		}
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) HandlerGetMyMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	pageSize, err := parsePageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := cursorFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.Db.ListMentionChirps(r.Context(), database.ListMentionChirpsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		log.Printf("Failed to fetch mentions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch mentions")
+		return
+	}
+
+	var page chirpsPage
+	if len(chirps) > int(pageSize) {
+		chirps = chirps[:pageSize]
+		last := chirps[len(chirps)-1].Chirp
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+	page.Chirps = make([]chirpsParams, 0, len(chirps))
+	for _, row := range chirps {
+		page.Chirps = append(page.Chirps, chirpResponse(row.Chirp).withLikes(row.LikeCount, row.LikedByMe, viewerID))
+	}
+	if err := cfg.attachDetails(r.Context(), page.Chirps); err != nil {
+		log.Printf("Failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch mentions")
+		return
+	}
+	respondWithJson(w, http.StatusOK, page)
+}
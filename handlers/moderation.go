@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/grainme/Chirpy/internal/moderation"
+)
+
+type wordListResponse struct {
+	Words []string `json:"words"`
+}
+
+func (cfg *ApiConfig) HandlerListProfanityWords(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+	respondWithJson(w, http.StatusOK, wordListResponse{Words: cfg.WordList.Words()})
+}
+
+func (cfg *ApiConfig) HandlerAddProfanityWord(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	type parameters struct {
+		Word string `json:"word"`
+	}
+	var params parameters
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		log.Printf("JSON Decode error: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	if _, err := moderation.NormalizeWord(params.Word); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := cfg.WordList.Add(r.Context(), params.Word); err != nil {
+		log.Printf("failed to add profanity word: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add word")
+		return
+	}
+
+	respondWithJson(w, http.StatusCreated, wordListResponse{Words: cfg.WordList.Words()})
+}
+
+func (cfg *ApiConfig) HandlerDeleteProfanityWord(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	word := r.PathValue("word")
+	if _, err := moderation.NormalizeWord(word); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := cfg.WordList.Remove(r.Context(), word); err != nil {
+		log.Printf("failed to remove profanity word: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove word")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// HandlerReloadProfanityWords re-reads the word list from its store, picking up
+// edits made by hand or by another instance.
+func (cfg *ApiConfig) HandlerReloadProfanityWords(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+	if err := cfg.WordList.Reload(r.Context()); err != nil {
+		log.Printf("failed to reload profanity words: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reload words")
+		return
+	}
+	respondWithJson(w, http.StatusOK, wordListResponse{Words: cfg.WordList.Words()})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor points at the last row of a page in (created_at, id) keyset order.
+// Clients only ever see it encoded, so its layout can change without breaking them.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	cursorID, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	return pageCursor{
+		CreatedAt: time.Unix(0, unixNano).UTC(),
+		ID:        cursorID,
+	}, nil
+}
+
+// rankedCursor is a pageCursor for result sets ordered by a relevance rank first.
+type rankedCursor struct {
+	Rank float32
+	pageCursor
+}
+
+func encodeRankedCursor(rank float32, createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + ":" + encodeCursor(createdAt, id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRankedCursor(cursor string) (rankedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return rankedCursor{}, errors.New("malformed cursor")
+	}
+
+	rank, inner, found := strings.Cut(string(raw), ":")
+	if !found {
+		return rankedCursor{}, errors.New("malformed cursor")
+	}
+	parsedRank, err := strconv.ParseFloat(rank, 32)
+	if err != nil {
+		return rankedCursor{}, errors.New("malformed cursor")
+	}
+	pc, err := decodeCursor(inner)
+	if err != nil {
+		return rankedCursor{}, err
+	}
+
+	return rankedCursor{Rank: float32(parsedRank), pageCursor: pc}, nil
+}
+
+// parsePageSize reads the ?limit= query value, falling back to defaultPageSize
+// when it's absent and rejecting anything outside 1..maxPageSize.
+func parsePageSize(limit string) (int32, error) {
+	if limit == "" {
+		return defaultPageSize, nil
+	}
+	size, err := strconv.Atoi(limit)
+	if err != nil || size < 1 || size > maxPageSize {
+		return 0, errors.New("limit must be a number between 1 and " + strconv.Itoa(maxPageSize))
+	}
+	return int32(size), nil
+}
+
+// cursorFromQuery decodes the ?cursor= query value into the nullable keyset
+// arguments the list queries take. No cursor means start from the first page.
+func cursorFromQuery(r *http.Request) (sql.NullTime, uuid.NullUUID, error) {
+	c := r.URL.Query().Get("cursor")
+	if c == "" {
+		return sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+	cursor, err := decodeCursor(c)
+	if err != nil {
+		return sql.NullTime{}, uuid.NullUUID{}, err
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}, nil
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/mail"
+	"github.com/grainme/Chirpy/internal/passwordpolicy"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	mailSendTimeout  = 30 * time.Second
+)
+
+var (
+	errResetTokenInvalid = errors.New("reset token is invalid or expired")
+	errPasswordRejected  = errors.New("password rejected by policy")
+)
+
+// HandlerForgotPassword emails a reset link to the account with that email.
+// It responds the same way whether or not there is one, so it can't be used
+// to find out who has an account.
+func (cfg *ApiConfig) HandlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.Db.GetUserByEmail(r.Context(), params.Email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to look up user for password reset: %v", err)
+		}
+		respondWithJson(w, http.StatusNoContent, nil)
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Could not generate reset token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if err := cfg.Db.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}); err != nil {
+		log.Printf("Could not store reset token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	link := cfg.BaseURL + "/app/reset-password?token=" + url.QueryEscape(token)
+	cfg.sendMailAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new one, open this link within %d minutes:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email and your password stays the same.\n",
+			int(passwordResetTTL.Minutes()), link),
+	})
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// HandlerResetPassword sets a new password using a token from a reset email.
+// It also logs every session out, in case whoever had the old password is
+// still signed in. The new password has to pass cfg.PasswordPolicy.
+func (cfg *ApiConfig) HandlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	var violations []passwordpolicy.Violation
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		reset, err := q.ConsumePasswordResetToken(r.Context(), auth.HashToken(params.Token))
+		if errors.Is(err, sql.ErrNoRows) {
+			return errResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		// a rejected password rolls back, so the link still works for the next try
+		user, err := q.FindUserById(r.Context(), reset.UserID)
+		if err != nil {
+			return err
+		}
+		violations, err = cfg.PasswordPolicy.Check(r.Context(), params.Password, user.Email)
+		if err != nil {
+			log.Printf("Failed to check password against breaches: %v", err)
+		}
+		if len(violations) > 0 {
+			return errPasswordRejected
+		}
+		hashedPassword, err := cfg.Passwords.Hash(params.Password)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.UpdateUser(r.Context(), database.UpdateUserParams{
+			ID:             reset.UserID,
+			HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+		}); err != nil {
+			return err
+		}
+		if err := q.InvalidatePasswordResetTokens(r.Context(), reset.UserID); err != nil {
+			return err
+		}
+		return q.RevokeUserRefreshTokens(r.Context(), reset.UserID)
+	})
+	if errors.Is(err, errPasswordRejected) {
+		respondWithPasswordViolations(w, violations)
+		return
+	}
+	if errors.Is(err, errResetTokenInvalid) {
+		respondWithError(w, http.StatusBadRequest, "Reset link is invalid or has expired")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reset password: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset password")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// sendMailAsync sends msg in the background so a slow mail server doesn't
+// hold up the request, or give away by its timing that an email was sent.
+func (cfg *ApiConfig) sendMailAsync(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		if err := cfg.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
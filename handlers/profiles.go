@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entities"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+// reservedHandles can't be picked because they'd shadow a route under
+// /api/users/.
+var reservedHandles = map[string]bool{
+	"me": true,
+}
+
+// profile is what anyone can see about a user. It deliberately has no email
+// and no password hash.
+type profile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle,omitempty"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	ChirpCount     int64     `json:"chirp_count"`
+}
+
+// HandlerGetUserProfile serves GET /api/users/{handleOrID}. A valid UUID is
+// looked up as an ID, anything else as a handle (with or without the '@').
+func (cfg *ApiConfig) HandlerGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("handleOrID")
+
+	var lookup database.GetUserProfileParams
+	if id, err := uuid.Parse(key); err == nil {
+		lookup.ID = uuid.NullUUID{UUID: id, Valid: true}
+	} else {
+		handle, ok := entities.NormalizeHandle(key)
+		if !ok {
+			respondWithError(w, http.StatusNotFound, "Couldn't find user")
+			return
+		}
+		lookup.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
+	row, err := cfg.Db.GetUserProfile(r.Context(), lookup)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch profile: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user")
+		return
+	}
+
+	isRed, err := cfg.Entitlements.IsRed(r.Context(), row.ID)
+	if err != nil {
+		log.Printf("Failed to look up entitlements: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, profile{
+		ID:             row.ID,
+		CreatedAt:      row.CreatedAt,
+		Handle:         row.Handle.String,
+		DisplayName:    row.DisplayName,
+		Bio:            row.Bio,
+		AvatarURL:      row.AvatarUrl,
+		IsChirpyRed:    isRed,
+		FollowerCount:  row.FollowerCount,
+		FollowingCount: row.FollowingCount,
+		ChirpCount:     row.ChirpCount,
+	})
+}
+
+// normalizeUserHandle checks a handle a user wants to claim.
+func normalizeUserHandle(handle string) (string, error) {
+	normalized, ok := entities.NormalizeHandle(handle)
+	if !ok {
+		return "", fmt.Errorf("Handle can only contain letters, digits and underscores (max %d)", entities.MaxHandleLength)
+	}
+	if reservedHandles[normalized] {
+		return "", fmt.Errorf("Handle %q is reserved", normalized)
+	}
+	return normalized, nil
+}
+
+// cleanDisplayName trims the name and runs it through the chirp filter, since
+// it's shown next to every chirp. An empty name is allowed and clears it.
+func (cfg *ApiConfig) cleanDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", fmt.Errorf("Display name is too long (max %d characters)", maxDisplayNameLength)
+	}
+	return cfg.ChirpFilter.Clean(name), nil
+}
+
+// cleanBio is cleanDisplayName for the bio.
+func (cfg *ApiConfig) cleanBio(bio string) (string, error) {
+	bio = strings.TrimSpace(bio)
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return "", fmt.Errorf("Bio is too long (max %d characters)", maxBioLength)
+	}
+	return cfg.ChirpFilter.Clean(bio), nil
+}
+
+// cleanAvatarURL accepts an absolute http(s) URL, or "" to clear the avatar.
+func cleanAvatarURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > maxAvatarURLLength {
+		return "", fmt.Errorf("Avatar URL is too long (max %d characters)", maxAvatarURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("Avatar URL must be an absolute http or https URL")
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+type searchResult struct {
+	chirpsParams
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"` // HTML: the escaped body with matches in <mark>
+}
+
+type searchPage struct {
+	Results    []searchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// HandlerSearchChirps runs a full-text search over chirp bodies. q accepts
+// web search syntax ("quoted phrases", or, -excluded), and the result can be
+// narrowed with author_id and a since/until date range (RFC 3339).
+func (cfg *ApiConfig) HandlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(w, http.StatusBadRequest, "Search query cannot be empty")
+		return
+	}
+
+	pageSize, err := parsePageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var authorID uuid.NullUUID
+	if a := r.URL.Query().Get("author_id"); a != "" {
+		userID, err := uuid.Parse(a)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Could not parse author_id into UUID format")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	since, err := parseTimeParam(r.URL.Query().Get("since"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+		return
+	}
+	until, err := parseTimeParam(r.URL.Query().Get("until"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "until must be an RFC 3339 timestamp")
+		return
+	}
+
+	var cursorRank sql.NullFloat64
+	var cursorCreatedAt sql.NullTime
+	var cursorID uuid.NullUUID
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := decodeRankedCursor(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		cursorRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		cursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		cursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	viewerID := cfg.viewerID(r)
+	rows, err := cfg.Db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		ViewerID:        viewerID,
+		Query:           query,
+		AuthorID:        authorID,
+		Since:           since,
+		Until:           until,
+		CursorRank:      cursorRank,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		PageSize:        pageSize + 1,
+	})
+	if err != nil {
+		log.Printf("Failed to search chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps")
+		return
+	}
+
+	var page searchPage
+	if len(rows) > int(pageSize) {
+		rows = rows[:pageSize]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeRankedCursor(last.Rank, last.Chirp.CreatedAt, last.Chirp.ID)
+	}
+
+	chirps := make([]chirpsParams, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, chirpResponse(row.Chirp).withLikes(row.LikeCount, row.LikedByMe, viewerID))
+	}
+	if err := cfg.attachDetails(r.Context(), chirps); err != nil {
+		log.Printf("Failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps")
+		return
+	}
+
+	page.Results = make([]searchResult, 0, len(rows))
+	for i, row := range rows {
+		page.Results = append(page.Results, searchResult{
+			chirpsParams: chirps[i],
+			Rank:         row.Rank,
+			Snippet:      row.Snippet,
+		})
+	}
+	respondWithJson(w, http.StatusOK, page)
+}
+
+func parseTimeParam(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	// created_at is stored as UTC without a zone
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
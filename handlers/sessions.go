@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+const maxUserAgentLength = 512
+
+// session is one login on one device: a refresh token family. Its ID is the
+// family ID and stays the same across refreshes.
+type session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+func (cfg *ApiConfig) HandlerListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Db.ListSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to fetch sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch sessions")
+		return
+	}
+
+	sessions := make([]session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, session{
+			ID:         row.FamilyID,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+		})
+	}
+	respondWithJson(w, http.StatusOK, sessions)
+}
+
+// HandlerRevokeSession logs one of the caller's sessions out. Access tokens
+// already handed to it stay valid until they expire.
+func (cfg *ApiConfig) HandlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find session")
+		return
+	}
+
+	revoked, err := cfg.Db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		log.Printf("Failed to revoke session: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find session")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// HandlerRevokeOtherSessions logs out every session except the one whose
+// refresh token is presented, the same way /api/revoke identifies it.
+func (cfg *ApiConfig) HandlerRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	bearerToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Bearer token is missing")
+		return
+	}
+
+	current, err := cfg.Db.GetRefreshToken(r.Context(), bearerToken)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (current.RevokedAt.Valid || current.ExpiresAt.Before(time.Now()))) {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token not found or expired")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch refresh token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions")
+		return
+	}
+
+	if err := cfg.Db.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   current.UserID,
+		FamilyID: current.FamilyID,
+	}); err != nil {
+		log.Printf("Failed to revoke sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is ignored
+// since anyone can set it; put a proxy that rewrites RemoteAddr in front of
+// Chirpy if you need the original client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
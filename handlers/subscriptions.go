@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/billing"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+const (
+	defaultPlan = "red"
+
+	// defaultBillingPeriod is assumed when an event doesn't say when the
+	// paid period ends.
+	defaultBillingPeriod = 30 * 24 * time.Hour
+
+	// DefaultSubscriptionGracePeriod is how long a user stays Red after
+	// their paid period ends, so a late renewal doesn't flicker them off.
+	DefaultSubscriptionGracePeriod = 3 * 24 * time.Hour
+)
+
+// subscription is a user's Chirpy Red subscription as they see it.
+type subscription struct {
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	GracePeriodEnd   time.Time `json:"grace_period_end"`
+}
+
+// applyBillingEvent does what a billing event, stored as the
+// webhook_events row eventID, asks. It returns errWebhookIgnored for events
+// Chirpy doesn't act on, or that leave nothing to change. Events are applied
+// in the order they happened, by event.OccurredAt, so one delivered late
+// (e.g. an upgrade retried after the downgrade that followed it) is ignored.
+func (cfg *ApiConfig) applyBillingEvent(ctx context.Context, eventID uuid.UUID, event billing.Event) error {
+	ref := uuid.NullUUID{UUID: eventID, Valid: true}
+	at := sql.NullTime{Time: event.OccurredAt, Valid: true}
+
+	switch event.Action {
+	case billing.ActionRenewed, billing.ActionStarted:
+		if event.Action == billing.ActionRenewed && event.CurrentPeriodEnd.IsZero() {
+			return cfg.renewSubscription(ctx, eventID, event)
+		}
+		periodEnd := event.CurrentPeriodEnd
+		if periodEnd.IsZero() {
+			periodEnd = time.Now().Add(defaultBillingPeriod)
+		}
+		_, err := cfg.Db.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+			Plan:             sql.NullString{String: event.Plan, Valid: event.Plan != ""},
+			DefaultPlan:      defaultPlan,
+			CurrentPeriodEnd: periodEnd,
+			GracePeriodEnd:   periodEnd.Add(cfg.SubscriptionGracePeriod),
+			EventID:          ref,
+			EventAt:          at,
+			UserID:           event.UserID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return cfg.subscriptionUnchanged(ctx, event.UserID)
+		}
+		return err
+
+	case billing.ActionPaymentFailed:
+		// still Red until the grace period runs out, unless a renewal comes first
+		_, err := cfg.Db.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			EventID: ref,
+			EventAt: at,
+			UserID:  event.UserID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errWebhookIgnored
+		}
+		return err
+
+	case billing.ActionCanceled:
+		_, err := cfg.Db.CancelSubscription(ctx, database.CancelSubscriptionParams{
+			EventID: ref,
+			EventAt: at,
+			UserID:  event.UserID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errWebhookIgnored
+		}
+		return err
+
+	default:
+		return errWebhookIgnored
+	}
+}
+
+// renewSubscription handles a renewal that doesn't say when the new period
+// ends. The period is extended in a single statement keyed on eventID, so
+// a concurrent or reprocessed run of the same event can't add it twice.
+//
+// Renewing early adds a period to the one already paid for, but only when
+// the provider gave the event an ID. Without one, every retry of the
+// delivery is stored as a new event, so the renewal only makes sure a
+// period from now is paid for, which a retry can't stretch.
+func (cfg *ApiConfig) renewSubscription(ctx context.Context, eventID uuid.UUID, event billing.Event) error {
+	var extend time.Duration
+	if event.ID != "" {
+		extend = defaultBillingPeriod
+	}
+	_, err := cfg.Db.RenewSubscription(ctx, database.RenewSubscriptionParams{
+		Plan:          sql.NullString{String: event.Plan, Valid: event.Plan != ""},
+		DefaultPlan:   defaultPlan,
+		PeriodSeconds: defaultBillingPeriod.Seconds(),
+		GraceSeconds:  cfg.SubscriptionGracePeriod.Seconds(),
+		EventID:       uuid.NullUUID{UUID: eventID, Valid: true},
+		EventAt:       sql.NullTime{Time: event.OccurredAt, Valid: true},
+		UserID:        event.UserID,
+		ExtendSeconds: extend.Seconds(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.subscriptionUnchanged(ctx, event.UserID)
+	}
+	return err
+}
+
+// subscriptionUnchanged says why starting or renewing userID's
+// subscription changed nothing: either there's no such user, or the event
+// was already applied or is out of date.
+func (cfg *ApiConfig) subscriptionUnchanged(ctx context.Context, userID uuid.UUID) error {
+	_, err := cfg.Db.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return errWebhookIgnored
+}
+
+// ExpireSubscriptionsEvery marks subscriptions whose grace period has run
+// out as expired, every interval. It never returns, so run it in its own
+// goroutine.
+func (cfg *ApiConfig) ExpireSubscriptionsEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		expired, err := cfg.Db.ExpireSubscriptions(context.Background())
+		if err != nil {
+			log.Printf("Failed to expire subscriptions: %v", err)
+			continue
+		}
+		if len(expired) > 0 {
+			log.Printf("Expired %d Chirpy Red subscriptions", len(expired))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+)
+
+type threadNode struct {
+	chirpsParams
+	Replies []threadNode `json:"replies"`
+}
+
+type threadResponse struct {
+	Ancestors []chirpsParams `json:"ancestors"`
+	Chirp     threadNode     `json:"chirp"`
+}
+
+func (cfg *ApiConfig) HandlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	depth := defaultThreadDepth
+	if d := r.URL.Query().Get("depth"); d != "" {
+		depth, err = strconv.Atoi(d)
+		if err != nil || depth < 1 || depth > maxThreadDepth {
+			respondWithError(w, http.StatusBadRequest, "depth must be a number between 1 and "+strconv.Itoa(maxThreadDepth))
+			return
+		}
+	}
+
+	// tombstones are allowed here, the thread is the only place they're visible
+	chirp, err := cfg.Db.GetChirpById(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp")
+		return
+	}
+
+	viewerID := cfg.viewerID(r)
+
+	ancestors, err := cfg.Db.GetChirpAncestors(r.Context(), database.GetChirpAncestorsParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		log.Printf("failed to fetch chirp ancestors: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch thread")
+		return
+	}
+
+	replies, err := cfg.Db.GetChirpReplies(r.Context(), database.GetChirpRepliesParams{
+		ID:       chirpID,
+		MaxDepth: int32(depth),
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		log.Printf("failed to fetch chirp replies: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch thread")
+		return
+	}
+
+	root, err := cfg.chirpView(r.Context(), chirp, viewerID)
+	if err != nil {
+		log.Printf("failed to fetch chirp details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch thread")
+		return
+	}
+
+	res := threadResponse{
+		Ancestors: make([]chirpsParams, 0, len(ancestors)),
+	}
+	for _, a := range ancestors {
+		res.Ancestors = append(res.Ancestors, chirpResponse(database.Chirp{
+			ID:        a.ID,
+			CreatedAt: a.CreatedAt,
+			UpdatedAt: a.UpdatedAt,
+			Body:      a.Body,
+			UserID:    a.UserID,
+			ParentID:  a.ParentID,
+			DeletedAt: a.DeletedAt,
+		}).withLikes(a.LikeCount, a.LikedByMe, viewerID))
+	}
+
+	flatReplies := make([]chirpsParams, 0, len(replies))
+	for _, reply := range replies {
+		flatReplies = append(flatReplies, chirpResponse(database.Chirp{
+			ID:        reply.ID,
+			CreatedAt: reply.CreatedAt,
+			UpdatedAt: reply.UpdatedAt,
+			Body:      reply.Body,
+			UserID:    reply.UserID,
+			ParentID:  reply.ParentID,
+			DeletedAt: reply.DeletedAt,
+		}).withLikes(reply.LikeCount, reply.LikedByMe, viewerID))
+	}
+
+	// one details lookup for the whole thread, then split it back up
+	all := make([]chirpsParams, 0, len(res.Ancestors)+len(flatReplies))
+	all = append(append(all, res.Ancestors...), flatReplies...)
+	if err := cfg.attachDetails(r.Context(), all); err != nil {
+		log.Printf("failed to fetch thread details: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch thread")
+		return
+	}
+	res.Ancestors, flatReplies = all[:len(res.Ancestors)], all[len(res.Ancestors):]
+
+	// replies come back flat in creation order, group them under their parent
+	children := make(map[uuid.UUID][]chirpsParams)
+	for _, reply := range flatReplies {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+	res.Chirp = buildThread(root, children)
+
+	respondWithJson(w, http.StatusOK, res)
+}
+
+func buildThread(chirp chirpsParams, children map[uuid.UUID][]chirpsParams) threadNode {
+	node := threadNode{
+		chirpsParams: chirp,
+		Replies:      make([]threadNode, 0, len(children[chirp.ID])),
+	}
+	for _, child := range children[chirp.ID] {
+		node.Replies = append(node.Replies, buildThread(child, children))
+	}
+	return node
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+const (
+	totpIssuer = "Chirpy"
+
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+// HandlerSetupTOTP starts 2FA enrollment with a new secret. 2FA isn't on
+// until the user proves their app has it through HandlerConfirmTOTP, and
+// calling this again before that replaces the secret.
+func (cfg *ApiConfig) HandlerSetupTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.Db.FindUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	enrollment, err := auth.NewTOTPEnrollment(totpIssuer, user.Email)
+	if err != nil {
+		log.Printf("Failed to generate TOTP secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start two-factor setup")
+		return
+	}
+
+	started, err := cfg.Db.StartTOTPEnrollment(r.Context(), database.StartTOTPEnrollmentParams{
+		ID:         userID,
+		TotpSecret: sql.NullString{String: enrollment.Secret, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Failed to store TOTP secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start two-factor setup")
+		return
+	}
+	if started == 0 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+		QRCodePNG       []byte `json:"qr_code_png"` // base64 in JSON
+	}{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.URI,
+		QRCodePNG:       enrollment.QRCode,
+	})
+}
+
+// HandlerConfirmTOTP turns 2FA on once the user sends a code from their app,
+// and responds with recovery codes. They're shown only this once.
+func (cfg *ApiConfig) HandlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.Db.FindUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Start two-factor setup first")
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.TotpSecret.String, params.Code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		log.Printf("Failed to generate recovery codes: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication")
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.EnableTOTP(r.Context(), database.EnableTOTPParams{ID: userID, TotpLastStep: step}); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(r.Context(), q, userID, codes)
+	})
+	if err != nil {
+		log.Printf("Failed to enable TOTP: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// HandlerDisableTOTP turns 2FA off. It takes a current code (or a recovery
+// code) so a stolen access token alone can't do it.
+func (cfg *ApiConfig) HandlerDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	user, err := cfg.Db.FindUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication isn't enabled")
+		return
+	}
+
+	verified, err := cfg.verifySecondFactor(r.Context(), user, params.Code)
+	if err != nil {
+		log.Printf("Failed to verify second factor: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication")
+		return
+	}
+	if !verified {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.DisableTOTP(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.DeleteRecoveryCodes(r.Context(), userID)
+	})
+	if err != nil {
+		log.Printf("Failed to disable TOTP: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// respondWithLoginChallenge is the first half of a 2FA login: the password
+// was right, and the client gets a short-lived token to send along with a
+// code to HandlerLogin2FA instead of real tokens.
+func (cfg *ApiConfig) respondWithLoginChallenge(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Could not generate challenge token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	expiresAt := time.Now().Add(loginChallengeTTL)
+	if err := cfg.Db.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		Token:     token,
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		log.Printf("Could not store challenge token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	respondWithJson(w, http.StatusOK, struct {
+		TwoFactorRequired bool      `json:"two_factor_required"`
+		ChallengeToken    string    `json:"challenge_token"`
+		ExpiresAt         time.Time `json:"expires_at"`
+	}{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// HandlerLogin2FA finishes a 2FA login. Each challenge allows a few
+// attempts, after which the user has to start over with their password.
+// Wrong codes also count towards the account's login lockout, so starting
+// over doesn't give unlimited guesses.
+func (cfg *ApiConfig) HandlerLogin2FA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+
+	var params parameters
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	challenge, err := cfg.Db.AttemptLoginChallenge(r.Context(), database.AttemptLoginChallengeParams{
+		Token:       params.ChallengeToken,
+		MaxAttempts: maxChallengeAttempts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "Login challenge expired, log in again")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch login challenge: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	user, err := cfg.Db.FindUserById(r.Context(), challenge.UserID)
+	if err != nil {
+		log.Printf("Failed to fetch user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+
+	userRef := uuid.NullUUID{UUID: user.ID, Valid: true}
+	if !cfg.reserveLogin(w, r, user.Email, userRef) {
+		return
+	}
+
+	verified, err := cfg.verifySecondFactor(r.Context(), user, params.Code)
+	if err != nil {
+		log.Printf("Failed to verify second factor: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if !verified {
+		cfg.failLogin(r, user.Email, userRef, loginSecondFactorFail)
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	// only one request gets to use the challenge
+	deleted, err := cfg.Db.DeleteLoginChallenge(r.Context(), challenge.Token)
+	if err != nil {
+		log.Printf("Failed to delete login challenge: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Login challenge expired, log in again")
+		return
+	}
+
+	cfg.succeedLogin(r, user.Email, user.ID)
+	cfg.startSession(w, r, user)
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code
+// for user, using it up either way: a TOTP code can't be used twice and a
+// recovery code only works once.
+func (cfg *ApiConfig) verifySecondFactor(ctx context.Context, user database.User, code string) (bool, error) {
+	if !user.TotpEnabledAt.Valid || code == "" {
+		return false, nil
+	}
+
+	if step, ok := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now()); ok {
+		used, err := cfg.Db.UseTOTPStep(ctx, database.UseTOTPStepParams{ID: user.ID, TotpLastStep: step})
+		return used == 1, err
+	}
+
+	used, err := cfg.Db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   user.ID,
+		CodeHash: auth.HashRecoveryCode(code),
+	})
+	return used == 1, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID, codes []string) error {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if err := q.AddRecoveryCode(ctx, database.AddRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -12,22 +13,79 @@ import (
 	"github.com/google/uuid"
 	"github.com/grainme/Chirpy/internal/auth"
 	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/mail"
+	"github.com/grainme/Chirpy/internal/passwordpolicy"
+	"github.com/lib/pq"
 )
 
+// User is the account as its owner sees it, email and tokens included.
+// Anyone else gets a profile instead.
 type User struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	JWTtoken     string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID     `json:"id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Email         string        `json:"email"`
+	EmailVerified bool          `json:"email_verified"`
+	PendingEmail  string        `json:"pending_email,omitempty"`
+	Handle        string        `json:"handle,omitempty"`
+	DisplayName   string        `json:"display_name"`
+	Bio           string        `json:"bio"`
+	AvatarURL     string        `json:"avatar_url"`
+	JWTtoken      string        `json:"token"`
+	RefreshToken  string        `json:"refresh_token"`
+	IsChirpyRed   bool          `json:"is_chirpy_red"`
+	Subscription  *subscription `json:"subscription,omitempty"`
+	TwoFactor     bool          `json:"two_factor_enabled"`
 }
+
+// userResponse maps a users row to its owner's view, without tokens.
+func (cfg *ApiConfig) userResponse(ctx context.Context, user database.User) (User, error) {
+	res := User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarUrl,
+		TwoFactor:     user.TotpEnabledAt.Valid,
+	}
+
+	isRed, err := cfg.Entitlements.IsRed(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+	res.IsChirpyRed = isRed
+
+	sub, err := cfg.Db.GetSubscription(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, nil
+	}
+	if err != nil {
+		return User{}, err
+	}
+	res.Subscription = &subscription{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		GracePeriodEnd:   sub.GracePeriodEnd,
+	}
+	return res, nil
+}
+
 type parameters struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Handle   string `json:"handle"`
 }
 
+// HandlerUserLogin checks an email and password. Failed attempts are
+// counted per account and per client IP, and too many get a 429 with a
+// Retry-After header until the lockout passes. A correct password stored
+// with weaker hashing parameters than cfg.Passwords is rehashed.
 func (cfg *ApiConfig) HandlerUserLogin(w http.ResponseWriter, r *http.Request) {
 	var params parameters
 	decoder := json.NewDecoder(r.Body)
@@ -37,50 +95,106 @@ func (cfg *ApiConfig) HandlerUserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// reserved before the password is checked, so a locked out client learns
+	// nothing and parallel guesses can't all get in before one is counted
+	if !cfg.reserveLogin(w, r, params.Email, uuid.NullUUID{}) {
+		return
+	}
+
 	// get user by email
 	user, errMail := cfg.Db.GetUserByEmail(r.Context(), params.Email)
-	match, errPassword := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	match, rehash, errPassword := cfg.Passwords.Check(params.Password, user.HashedPassword)
 	if errMail != nil || errPassword != nil || !match {
 		log.Printf("Incorrect email or password: \n%v\n%v", errMail, errPassword)
+		cfg.failLogin(r, params.Email, uuid.NullUUID{UUID: user.ID, Valid: errMail == nil}, loginFailed)
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
+	if rehash {
+		cfg.rehashPassword(r, user, params.Password)
+	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.JWTSecretToken, time.Hour)
+	// the account's failures only reset once the second factor is in too
+	if user.TotpEnabledAt.Valid {
+		cfg.passLogin(r, params.Email, user.ID)
+		cfg.respondWithLoginChallenge(w, r, user)
+		return
+	}
+	cfg.succeedLogin(r, params.Email, user.ID)
+	cfg.startSession(w, r, user)
+}
+
+// checkPassword responds with the password policy's complaints and returns
+// false if password isn't good enough for the account with that email. If
+// the breach check can't be done, the password is let through.
+func (cfg *ApiConfig) checkPassword(w http.ResponseWriter, r *http.Request, password, email string) bool {
+	violations, err := cfg.PasswordPolicy.Check(r.Context(), password, email)
 	if err != nil {
-		log.Printf("Could not make JWT Token: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		log.Printf("Failed to check password against breaches: %v", err)
+		return true
+	}
+	if len(violations) == 0 {
+		return true
+	}
+	respondWithPasswordViolations(w, violations)
+	return false
+}
+
+func respondWithPasswordViolations(w http.ResponseWriter, violations []passwordpolicy.Violation) {
+	fields := make([]fieldError, len(violations))
+	for i, v := range violations {
+		fields[i] = fieldError{Field: "password", Code: v.Code, Message: v.Message}
+	}
+	respondWithFieldErrors(w, http.StatusBadRequest, violations[0].Message, fields)
+}
+
+// rehashPassword upgrades user's stored hash to the current parameters.
+// Failing is fine: the old hash still works and we'll try again next login.
+func (cfg *ApiConfig) rehashPassword(r *http.Request, user database.User, password string) {
+	hash, err := cfg.Passwords.Hash(password)
+	if err != nil {
+		log.Printf("Password rehashing failed: %v", err)
 		return
 	}
+	if err := cfg.Db.RehashPassword(r.Context(), database.RehashPasswordParams{
+		NewHash: hash,
+		ID:      user.ID,
+		OldHash: user.HashedPassword,
+	}); err != nil {
+		log.Printf("Failed to store rehashed password: %v", err)
+	}
+}
 
-	refreshToken, err := auth.MakeRefreshToken()
+// startSession logs user in: it responds with the user, a new access token
+// and the first refresh token of a new family.
+func (cfg *ApiConfig) startSession(w http.ResponseWriter, r *http.Request, user database.User) {
+	token, err := cfg.JWTKeys.MakeJWT(user.ID, time.Hour)
 	if err != nil {
-		log.Printf("Could not generate Refresh Token: %v", err)
+		log.Printf("Could not make JWT Token: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
-	refreshTokenCreated, err := cfg.Db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
-	})
+
+	refreshTokenCreated, err := createRefreshToken(r, cfg.Db, user.ID, uuid.New())
 	if err != nil {
 		log.Printf("Could not store Refresh Token: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
 
-	respondWithJson(w, http.StatusOK, User{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		JWTtoken:     token,
-		RefreshToken: refreshTokenCreated.Token,
-		IsChirpyRed:  user.IsChirpyRed,
-	})
+	res, err := cfg.userResponse(r.Context(), user)
+	if err != nil {
+		log.Printf("Could not fetch subscription: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	res.JWTtoken = token
+	res.RefreshToken = refreshTokenCreated.Token
+	respondWithJson(w, http.StatusOK, res)
 }
 
+// HandlerInsertUser signs a user up and emails them a link to verify
+// their address.
 func (cfg *ApiConfig) HandlerInsertUser(w http.ResponseWriter, r *http.Request) {
 	var params parameters
 	decoder := json.NewDecoder(r.Body)
@@ -90,82 +204,225 @@ func (cfg *ApiConfig) HandlerInsertUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hash, err := auth.HashPassword(params.Password)
+	email, err := normalizeEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !cfg.checkPassword(w, r, params.Password, email) {
+		return
+	}
+	hash, err := cfg.Passwords.Hash(params.Password)
 	if err != nil {
 		log.Printf("Password hashing failed: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash user's password")
 		return
 	}
 
-	dbData, err := cfg.Db.CreateUser(r.Context(), database.CreateUserParams{
-		ID:             uuid.New(),
-		Email:          params.Email,
-		HashedPassword: hash,
+	var handle sql.NullString
+	if params.Handle != "" {
+		normalized, err := normalizeUserHandle(params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		handle = sql.NullString{String: normalized, Valid: true}
+	}
+
+	var (
+		dbData       database.User
+		verification mail.Message
+	)
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		dbData, err = q.CreateUser(r.Context(), database.CreateUserParams{
+			ID:             uuid.New(),
+			Email:          email,
+			HashedPassword: hash,
+			Handle:         handle,
+		})
+		if err != nil {
+			return err
+		}
+		verification, err = cfg.createEmailVerification(r.Context(), q, dbData.ID, email)
+		return err
 	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle is already taken")
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to create user: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create the user")
 		return
 	}
+	cfg.sendMailAsync(verification)
 
-	respondWithJson(w, http.StatusCreated, User{
-		ID:          dbData.ID,
-		CreatedAt:   dbData.CreatedAt,
-		UpdatedAt:   dbData.UpdatedAt,
-		Email:       dbData.Email,
-		IsChirpyRed: dbData.IsChirpyRed,
-	})
+	res, err := cfg.userResponse(r.Context(), dbData)
+	if err != nil {
+		log.Printf("Could not fetch subscription: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	respondWithJson(w, http.StatusCreated, res)
 }
 
+// HandlerUpdateUser changes only the fields present in the body, so a client
+// can edit its bio without resending the password. Changing the password
+// revokes every refresh token the user has. A new email is only pending
+// until the user opens the link sent to it; sending the current email
+// cancels a pending change.
 func (cfg *ApiConfig) HandlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email       *string `json:"email"`
+		Password    *string `json:"password"`
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+	}
+
+	userID, ok := cfg.authenticate(w, r)
+	if !ok {
+		return
+	}
+
 	var params parameters
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&params); err != nil {
 		log.Printf("%v", err)
-		respondWithError(w, http.StatusUnauthorized, "Email and password not found")
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		log.Printf("Password hashing failed: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash user's password")
-		return
+	update := database.UpdateUserParams{ID: userID}
+	var email string
+	if params.Email != nil {
+		var err error
+		email, err = normalizeEmail(*params.Email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// checked now so the user hears about it before any email is sent;
+		// HandlerVerifyEmail checks again in case someone takes it meanwhile
+		owner, err := cfg.Db.GetUserByEmail(r.Context(), email)
+		if err == nil && owner.ID != userID {
+			respondWithError(w, http.StatusConflict, "Email is already taken")
+			return
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to look up email: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update user")
+			return
+		}
+	}
+	if params.Password != nil {
+		// judged against the email the account is about to have
+		if params.Email == nil {
+			user, err := cfg.Db.FindUserById(r.Context(), userID)
+			if err != nil {
+				respondWithError(w, http.StatusNotFound, "Couldn't find user")
+				return
+			}
+			email = user.Email
+		}
+		if !cfg.checkPassword(w, r, *params.Password, email) {
+			return
+		}
+		hashedPassword, err := cfg.Passwords.Hash(*params.Password)
+		if err != nil {
+			log.Printf("Password hashing failed: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Couldn't hash user's password")
+			return
+		}
+		update.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+	if params.Handle != nil {
+		handle, err := normalizeUserHandle(*params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.Handle = sql.NullString{String: handle, Valid: true}
+	}
+	if params.DisplayName != nil {
+		displayName, err := cfg.cleanDisplayName(*params.DisplayName)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.DisplayName = sql.NullString{String: displayName, Valid: true}
+	}
+	if params.Bio != nil {
+		bio, err := cfg.cleanBio(*params.Bio)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.Bio = sql.NullString{String: bio, Valid: true}
+	}
+	if params.AvatarURL != nil {
+		avatarURL, err := cleanAvatarURL(*params.AvatarURL)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.AvatarUrl = sql.NullString{String: avatarURL, Valid: true}
 	}
 
-	authorizationValue := strings.Fields(r.Header.Get("Authorization"))
-	if len(authorizationValue) < 2 {
-		log.Printf("Authorization header: %v", authorizationValue)
-		respondWithError(w, http.StatusUnauthorized, "Bearer token not provided")
+	// a new password logs out every session, including the caller's
+	var (
+		updatedUser  database.User
+		verification *mail.Message
+	)
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		updatedUser, err = q.UpdateUser(r.Context(), update)
+		if err != nil {
+			return err
+		}
+		if update.HashedPassword.Valid {
+			if err := q.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+				return err
+			}
+		}
+		if params.Email == nil {
+			return nil
+		}
+
+		updatedUser, err = q.SetPendingEmail(r.Context(), database.SetPendingEmailParams{
+			PendingEmail: email,
+			ID:           userID,
+		})
+		if err != nil || !updatedUser.PendingEmail.Valid {
+			return err
+		}
+		msg, err := cfg.createEmailVerification(r.Context(), q, userID, email)
+		verification = &msg
+		return err
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle is already taken")
 		return
 	}
-
-	bearerToken := authorizationValue[1]
-	userID, err := auth.ValidateJWT(bearerToken, cfg.JWTSecretToken)
 	if err != nil {
 		log.Printf("%v", err)
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user")
 		return
 	}
+	if verification != nil {
+		cfg.sendMailAsync(*verification)
+	}
 
-	updatedUser, err := cfg.Db.UpdateUser(r.Context(), database.UpdateUserParams{
-		Email:          params.Email,
-		HashedPassword: hashedPassword,
-		ID:             userID,
-	})
+	res, err := cfg.userResponse(r.Context(), updatedUser)
 	if err != nil {
-		log.Printf("%v", err)
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user")
+		log.Printf("Could not fetch subscription: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
 		return
 	}
-
-	respondWithJson(w, http.StatusOK, User{
-		ID:          updatedUser.ID,
-		CreatedAt:   updatedUser.CreatedAt,
-		UpdatedAt:   updatedUser.UpdatedAt,
-		Email:       updatedUser.Email,
-		IsChirpyRed: updatedUser.IsChirpyRed,
-	})
+	respondWithJson(w, http.StatusOK, res)
 }
 
 func (cfg *ApiConfig) HandlerReset(w http.ResponseWriter, r *http.Request) {
@@ -184,6 +441,10 @@ func (cfg *ApiConfig) HandlerReset(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Hits reset to 0 and database reset to initial state."))
 }
 
+// HandlerRefresh trades a refresh token for a new access token and a new
+// refresh token; the presented one stops working. Presenting a token that
+// was already traded in means someone else has a copy, so every token
+// descended from the same login is revoked.
 func (cfg *ApiConfig) HandlerRefresh(w http.ResponseWriter, r *http.Request) {
 	authorizationValue := strings.Fields(r.Header.Get("Authorization"))
 	if len(authorizationValue) < 2 {
@@ -193,14 +454,49 @@ func (cfg *ApiConfig) HandlerRefresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	bearerToken := authorizationValue[1]
-	refreshTokenRow, err := cfg.Db.GetRefreshToken(r.Context(), bearerToken)
-	if err != nil || refreshTokenRow.ExpiresAt.Before(time.Now()) || refreshTokenRow.RevokedAt.Valid {
-		log.Printf("Refresh token not found or expired: %v", authorizationValue)
+	var (
+		rotated database.RefreshToken
+		reused  bool
+	)
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		current, err := q.GetRefreshTokenForUpdate(r.Context(), bearerToken)
+		if err != nil {
+			return err
+		}
+		if current.ReplacedBy.Valid {
+			reused = true
+			return q.RevokeRefreshTokenFamily(r.Context(), current.FamilyID)
+		}
+		if current.RevokedAt.Valid || current.ExpiresAt.Before(time.Now()) {
+			return errRefreshTokenInvalid
+		}
+
+		rotated, err = createRefreshToken(r, q, current.UserID, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		return q.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+			ReplacedBy: sql.NullString{String: rotated.Token, Valid: true},
+			Token:      current.Token,
+		})
+	})
+	if reused && err == nil {
+		log.Printf("Refresh token reused, revoked its family")
+		respondWithError(w, http.StatusUnauthorized, "Refresh token not found or expired")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errRefreshTokenInvalid) {
+		log.Printf("Refresh token not found or expired: %v", err)
 		respondWithError(w, http.StatusUnauthorized, "Refresh token not found or expired")
 		return
 	}
+	if err != nil {
+		log.Printf("Could not rotate Refresh Token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
 
-	newJWT, err := auth.MakeJWT(refreshTokenRow.UserID, cfg.JWTSecretToken, time.Hour)
+	newJWT, err := cfg.JWTKeys.MakeJWT(rotated.UserID, time.Hour)
 	if err != nil {
 		log.Printf("Could not make JWT Token: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
@@ -208,9 +504,11 @@ func (cfg *ApiConfig) HandlerRefresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token := struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
-		Token: newJWT,
+		Token:        newJWT,
+		RefreshToken: rotated.Token,
 	}
 	respondWithJson(w, http.StatusOK, token)
 }
@@ -234,49 +532,33 @@ func (cfg *ApiConfig) HandlerRevoke(w http.ResponseWriter, r *http.Request) {
 	respondWithJson(w, http.StatusNoContent, nil)
 }
 
-func (cfg *ApiConfig) HandlerUpgradeUser(w http.ResponseWriter, r *http.Request) {
-	header := r.Header.Get("Authorization")
-	apiKey := strings.Fields(header)
-	if len(apiKey) < 2 {
-		respondWithError(w, http.StatusUnauthorized, "Api key not found")
-		return
-	}
-	if strings.TrimSpace(apiKey[1]) != cfg.PolkaKey {
-		respondWithError(w, http.StatusUnauthorized, "Api key mismatch")
-		return
-	}
-
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserId uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-
-	var params parameters
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&params); err != nil {
-		log.Printf("%v", err)
-		respondWithError(w, http.StatusUnauthorized, "Email and password not found")
-		return
-	}
+// refreshTokenTTL is how long a refresh token lasts if it's never used.
+// Rotating one starts the clock again for its replacement.
+const refreshTokenTTL = 60 * 24 * time.Hour
 
-	if params.Event != "user.upgraded" {
-		respondWithJson(w, http.StatusNoContent, nil)
-		return
-	}
+var errRefreshTokenInvalid = errors.New("refresh token revoked or expired")
 
-	_, err := cfg.Db.UpgradeUser(r.Context(), params.Data.UserId)
+// createRefreshToken stores a fresh refresh token for userID, tagged with
+// the client that asked for it. Pass a new familyID at login and the
+// presented token's family when rotating.
+func createRefreshToken(r *http.Request, q *database.Queries, userID, familyID uuid.UUID) (database.RefreshToken, error) {
+	token, err := auth.MakeRefreshToken()
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("Couldn't find user: %v", err)
-			respondWithError(w, http.StatusNotFound, "Couldn't find user")
-			return
-		}
-		log.Printf("Could not upgrade user's membership: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Could not upgrade user's membership")
-		return
+		return database.RefreshToken{}, err
 	}
+	return q.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		FamilyID:  familyID,
+		UserAgent: truncate(r.UserAgent(), maxUserAgentLength),
+		IpAddress: clientIP(r),
+	})
+}
 
-	respondWithJson(w, http.StatusNoContent, nil)
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value for a UNIQUE column.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
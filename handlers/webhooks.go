@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/billing"
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+// maxWebhookBody is more than any provider's event needs.
+const maxWebhookBody = 64 << 10
+
+// webhookClaimLease is how long a claim on an event lasts. A claim older
+// than this is taken to belong to a request that died, and the event can
+// be claimed again by a retry or reprocessed.
+const webhookClaimLease = 5 * time.Minute
+
+// Statuses of a webhook_events row.
+const (
+	webhookProcessed = "processed"
+	webhookIgnored   = "ignored"
+	webhookFailed    = "failed"
+)
+
+var (
+	errWebhookUserNotFound = errors.New("user not found")
+	errWebhookIgnored      = errors.New("event type not handled")
+)
+
+// webhookEvent is a webhook_events row as the admin API shows it.
+type webhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	UserID      *uuid.UUID      `json:"user_id"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func webhookEventResponse(e database.WebhookEvent) webhookEvent {
+	res := webhookEvent{
+		ID:         e.ID,
+		Provider:   e.Provider,
+		EventID:    e.EventID,
+		EventType:  e.EventType,
+		Payload:    json.RawMessage(e.Payload),
+		ReceivedAt: e.ReceivedAt,
+		Status:     e.Status,
+		Attempts:   e.Attempts,
+		LastError:  e.LastError.String,
+	}
+	if e.UserID.Valid {
+		res.UserID = &e.UserID.UUID
+	}
+	if e.ProcessedAt.Valid {
+		res.ProcessedAt = &e.ProcessedAt.Time
+	}
+	return res
+}
+
+// HandlerBillingWebhook handles webhooks from the billing provider named
+// in the path, which checks the delivery is genuine and says what it means.
+//
+// Every delivery is stored in webhook_events before it's acted on.
+// Providers retry until they get a 2xx, so an event ID we've already
+// handled, or are handling right now, is acknowledged without doing
+// anything again, unless the claim on it has outlived webhookClaimLease.
+// Events without an ID get one per delivery, since a repeat can't be told
+// from a new event that looks the same.
+func (cfg *ApiConfig) HandlerBillingWebhook(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.BillingProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown billing provider")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Couldn't read body")
+		return
+	}
+	if err := provider.Verify(r.Header, body); err != nil {
+		log.Printf("Rejected %s webhook: %v", provider.Name(), err)
+		respondWithError(w, http.StatusUnauthorized, "Couldn't authenticate webhook")
+		return
+	}
+
+	event, err := provider.Parse(body)
+	if err != nil {
+		log.Printf("%v", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters")
+		return
+	}
+
+	rowID := uuid.New()
+	if event.ID == "" {
+		event.ID = "delivery:" + rowID.String()
+	}
+	stored, err := cfg.Db.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		ID:        rowID,
+		Provider:  provider.Name(),
+		EventID:   event.ID,
+		EventType: event.Type,
+		UserID:    uuid.NullUUID{UUID: event.UserID, Valid: event.UserID != uuid.Nil},
+		Payload:   string(body),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		stored, err = cfg.Db.GetWebhookEventByEventID(r.Context(), database.GetWebhookEventByEventIDParams{
+			Provider: provider.Name(),
+			EventID:  event.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to store %s event: %v", provider.Name(), err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store event")
+		return
+	}
+
+	// only one delivery gets to act on an event, and only until it's done
+	claimed, err := cfg.claimWebhookEvent(r.Context(), stored.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("%s event %s already %s", provider.Name(), event.ID, stored.Status)
+		respondWithJson(w, http.StatusNoContent, nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to claim %s event: %v", provider.Name(), err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store event")
+		return
+	}
+
+	_, procErr, err := cfg.processWebhookEvent(r.Context(), claimed)
+	if errors.Is(procErr, errWebhookUserNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user")
+		return
+	}
+	if err := errors.Join(procErr, err); err != nil {
+		log.Printf("Could not process %s event %s: %v", provider.Name(), event.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not update user's membership")
+		return
+	}
+
+	respondWithJson(w, http.StatusNoContent, nil)
+}
+
+// HandlerPolkaWebhook serves Polka's original webhook URL, from before
+// billing providers were mounted under /api/billing.
+func (cfg *ApiConfig) HandlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	r.SetPathValue("provider", billing.Polka{}.Name())
+	cfg.HandlerBillingWebhook(w, r)
+}
+
+// claimWebhookEvent claims the event for this request, taking over a claim
+// that has outlived webhookClaimLease. sql.ErrNoRows means it can't be
+// claimed.
+func (cfg *ApiConfig) claimWebhookEvent(ctx context.Context, id uuid.UUID) (database.WebhookEvent, error) {
+	return cfg.Db.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+		ID:          id,
+		StaleBefore: time.Now().Add(-webhookClaimLease),
+	})
+}
+
+// processWebhookEvent acts on an event claimed with claimWebhookEvent and
+// records the outcome on it. It carries on if the request goes away, so a
+// claimed event isn't left half done with nothing to say so.
+// procErr is why the event failed, if it did; err means the outcome
+// couldn't be recorded.
+func (cfg *ApiConfig) processWebhookEvent(ctx context.Context, stored database.WebhookEvent) (finished database.WebhookEvent, procErr, err error) {
+	ctx = context.WithoutCancel(ctx)
+	status := webhookProcessed
+	if provider, ok := cfg.BillingProviders[stored.Provider]; !ok {
+		procErr = fmt.Errorf("billing provider %q is not configured", stored.Provider)
+	} else if event, parseErr := provider.Parse([]byte(stored.Payload)); parseErr != nil {
+		procErr = parseErr
+	} else {
+		if event.OccurredAt.IsZero() {
+			// a retry keeps the row of the first delivery, so this is still
+			// when the event first reached us
+			event.OccurredAt = stored.ReceivedAt
+		}
+		procErr = cfg.applyBillingEvent(ctx, stored.ID, event)
+	}
+	switch {
+	case errors.Is(procErr, errWebhookIgnored):
+		status, procErr = webhookIgnored, nil
+	case procErr != nil:
+		status = webhookFailed
+	}
+
+	var lastError sql.NullString
+	if procErr != nil {
+		lastError = sql.NullString{String: procErr.Error(), Valid: true}
+	}
+	finished, err = cfg.Db.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		Status:    status,
+		LastError: lastError,
+		ID:        stored.ID,
+		ClaimedAt: stored.ClaimedAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errors.New("claim on the event went stale and was taken over")
+	}
+	return finished, procErr, err
+}
+
+type webhookEventsPage struct {
+	Events     []webhookEvent `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// HandlerListWebhookEvents lists stored webhook events, newest first,
+// optionally filtered by ?provider=, ?status= and ?user_id=.
+func (cfg *ApiConfig) HandlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	pageSize, err := parsePageSize(query.Get("limit"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursorReceivedAt, cursorID, err := cursorFromQuery(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params := database.ListWebhookEventsParams{
+		Provider:         sql.NullString{String: query.Get("provider"), Valid: query.Get("provider") != ""},
+		Status:           sql.NullString{String: query.Get("status"), Valid: query.Get("status") != ""},
+		CursorReceivedAt: cursorReceivedAt,
+		CursorID:         cursorID,
+		PageSize:         pageSize + 1,
+	}
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Could not parse user_id into UUID format")
+			return
+		}
+		params.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	events, err := cfg.Db.ListWebhookEvents(r.Context(), params)
+	if err != nil {
+		log.Printf("Failed to list webhook events: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list webhook events")
+		return
+	}
+
+	var page webhookEventsPage
+	if len(events) > int(pageSize) {
+		events = events[:pageSize]
+		last := events[len(events)-1]
+		page.NextCursor = encodeCursor(last.ReceivedAt, last.ID)
+	}
+	page.Events = make([]webhookEvent, 0, len(events))
+	for _, e := range events {
+		page.Events = append(page.Events, webhookEventResponse(e))
+	}
+	respondWithJson(w, http.StatusOK, page)
+}
+
+// HandlerReprocessWebhookEvent runs a failed event again, e.g. once the
+// user it names exists, and responds with the event as it now stands.
+// An event whose claim has outlived webhookClaimLease counts as failed.
+func (cfg *ApiConfig) HandlerReprocessWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not parse eventID into UUID format")
+		return
+	}
+	stored, err := cfg.Db.GetWebhookEvent(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook event")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch webhook event: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch webhook event")
+		return
+	}
+	if stored.Status == webhookProcessed || stored.Status == webhookIgnored {
+		respondWithError(w, http.StatusConflict, "Only failed events can be reprocessed")
+		return
+	}
+	claimed, err := cfg.claimWebhookEvent(r.Context(), stored.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Event is already being processed")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to claim webhook event: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reprocess webhook event")
+		return
+	}
+
+	// if it fails again, the new error is on the event we respond with
+	finished, _, err := cfg.processWebhookEvent(r.Context(), claimed)
+	if err != nil {
+		log.Printf("Failed to reprocess webhook event: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reprocess webhook event")
+		return
+	}
+	respondWithJson(w, http.StatusOK, webhookEventResponse(finished))
+}
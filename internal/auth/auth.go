@@ -3,6 +3,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,13 +12,15 @@ import (
 	"time"
 
 	"github.com/alexedwards/argon2id"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // HashPassword creates a secure hash of a plaintext password using Argon2id.
 // This is a one-way operation - you can't reverse the hash back to the password.
 // Use this when storing passwords in the database.
+//
+// It always uses argon2id.DefaultParams; see PasswordHasher in passwords.go
+// for choosing stronger ones and upgrading old hashes.
 func HashPassword(password string) (string, error) {
 	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
 	if err != nil {
@@ -55,24 +58,9 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 // The signature ensures the token can't be tampered with - if someone changes
 // the userID, the signature won't match and validation will fail.
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	// Create token with claims (the data inside the JWT)
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS256, // Symmetric signing: same key signs and validates
-		jwt.RegisteredClaims{
-			Issuer:    "chirpy",                                      // Who created this token
-			IssuedAt:  jwt.NewNumericDate(time.Now()),                // When it was created
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)), // When it expires
-			Subject:   userID.String(),                               // Who this token is for (the user)
-		},
-	)
-
-	// Sign the token with our secret key (converts to []byte for HS256)
-	// This creates the signature that proves authenticity
-	signedJWT, err := token.SignedString([]byte(tokenSecret))
-	if err != nil {
-		return "", err
-	}
-	return signedJWT, nil
+	// A one-key HS256 Keyring does exactly this; see keyring.go for the
+	// claims we set and for asymmetric keys.
+	return NewHMACKeyring(tokenSecret).MakeJWT(userID, expiresIn)
 }
 
 // ValidateJWT verifies a JWT's signature and extracts the user ID from it.
@@ -89,36 +77,7 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 //   - Token has expired
 //   - Token is malformed
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	// Parse the token string and validate its signature
-	// The empty &jwt.RegisteredClaims{} will be filled with the token's data
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&jwt.RegisteredClaims{}, // Pointer so ParseWithClaims can fill it in
-		func(t *jwt.Token) (any, error) {
-			// This function is called during validation to get the secret key
-			// For HS256: return []byte of the secret
-			// For ES256/ES512: return *ecdsa.PublicKey
-			return []byte(tokenSecret), nil
-		},
-	)
-	// token is invalid (bad signature, expired, or malformed)
-	if err != nil {
-		return uuid.Nil, err
-	}
-
-	// Type assertion: convert the interface{} to *jwt.RegisteredClaims
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
-	if !ok || !token.Valid {
-		return uuid.Nil, errors.New("invalid token claims")
-	}
-
-	// Extract user ID from the Subject field (we put it there in MakeJWT)
-	userID, err := uuid.Parse(claims.Subject)
-	if err != nil {
-		return uuid.Nil, err
-	}
-
-	return userID, nil
+	return NewHMACKeyring(tokenSecret).ValidateJWT(tokenString)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -141,6 +100,14 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// HashToken hashes a random token (a password reset token, say) for storage.
+// We only ever need to look tokens up, never read them back, and they're too
+// random to guess, so a plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	header := headers.Get("Authorization")
 	apiKey := strings.Fields(header)
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Key is one key a Keyring can sign or verify JWTs with.
+//
+// Asymmetric keys (Ed25519 -> EdDSA, RSA -> RS256) are the ones you want:
+// Chirpy signs with the private half and publishes the public half in the
+// JWKS, so other services can verify our tokens without being able to mint
+// them. A retired key keeps only its public half.
+//
+// An HMAC key (HS256) is the old shared-secret setup. It can't be published,
+// so it never shows up in the JWKS.
+type Key struct {
+	ID     string // the "kid" header of tokens signed with this key
+	Method jwt.SigningMethod
+
+	signKey   any // *rsa.PrivateKey, ed25519.PrivateKey, []byte or nil
+	verifyKey any // *rsa.PublicKey, ed25519.PublicKey or []byte
+}
+
+// CanSign reports whether the private half of the key is available.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// ParseKey reads a PEM encoded key. "PRIVATE KEY" (PKCS#8) and
+// "RSA PRIVATE KEY" (PKCS#1) blocks give a key that can sign; a
+// "PUBLIC KEY" (PKIX) block gives a verify-only key.
+func ParseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM block found", id)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: k}, nil
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", id, parsed)
+	}
+}
+
+// Keyring signs access tokens with one key and verifies them with any of
+// its keys, picked by the token's "kid" header.
+//
+// Rotating keys without logging anyone out goes like this:
+//  1. add the new key to the ring, keep signing with the old one
+//  2. once the JWKS with the new key has reached every verifier, sign with it
+//  3. after the longest token lifetime, replace the old private key with its
+//     public half (or drop it)
+type Keyring struct {
+	// Validation is checked on every token and supplies the issuer and
+	// audience of the ones we sign. It starts as DefaultValidation.
+	Validation Validation
+
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeyring builds a Keyring that signs with the key named signingID.
+func NewKeyring(keys []*Key, signingID string) (*Keyring, error) {
+	kr := &Keyring{Validation: DefaultValidation, keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, dup := kr.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		kr.keys[k.ID] = k
+	}
+
+	signing, ok := kr.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", signingID)
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingID)
+	}
+	kr.signing = signing
+	return kr, nil
+}
+
+// NewHMACKeyring is a Keyring holding a single HS256 secret, i.e. the
+// original JWT_SecretToken behaviour. Its tokens carry no "kid".
+func NewHMACKeyring(secret string) *Keyring {
+	key := &Key{Method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+	return &Keyring{Validation: DefaultValidation, signing: key, keys: map[string]*Key{"": key}}
+}
+
+// LoadKeyring reads every *.pem file in dir, using the file name without
+// the extension as the key ID, and signs with the key named signingID.
+func LoadKeyring(dir, signingID string) (*Keyring, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .pem files in %s", dir)
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyring(keys, signingID)
+}
+
+// MakeJWT is the package level MakeJWT, signed with the ring's signing key
+// and with its ID in the "kid" header.
+func (kr *Keyring) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    kr.Validation.Issuer,                          // Who created this token
+		IssuedAt:  jwt.NewNumericDate(time.Now()),                // When it was created
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)), // When it expires
+		Subject:   userID.String(),                               // Who this token is for (the user)
+	}
+	// Who may accept this token (other services check it's them)
+	if kr.Validation.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{kr.Validation.Audience}
+	}
+
+	// HS256, EdDSA or RS256, whatever the signing key is
+	token := jwt.NewWithClaims(kr.signing.Method, claims)
+	// The "kid" header tells verifiers which key in the JWKS to check it with
+	if kr.signing.ID != "" {
+		token.Header["kid"] = kr.signing.ID
+	}
+	return token.SignedString(kr.signing.signKey)
+}
+
+// ValidateJWT is the package level ValidateJWT, looking the key up by the
+// token's "kid" and enforcing kr.Validation. A token only validates with the
+// algorithm of the key it names, so nobody can pass off an HS256 token
+// "signed" with our public key. Errors are one of the ErrToken* values.
+func (kr *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(
+		tokenString,
+		&jwt.RegisteredClaims{},
+		func(t *jwt.Token) (any, error) {
+			alg := t.Method.Alg()
+			if !kr.Validation.allows(alg) {
+				return nil, fmt.Errorf("%w: %s", ErrTokenAlgorithm, alg)
+			}
+			kid, _ := t.Header["kid"].(string)
+			key, ok := kr.keys[kid]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrTokenUnknownKey, kid)
+			}
+			if alg != key.Method.Alg() {
+				return nil, fmt.Errorf("%w: key %q doesn't sign with %s", ErrTokenAlgorithm, kid, alg)
+			}
+			return key.verifyKey, nil
+		},
+		kr.Validation.parserOptions()...,
+	)
+	if err != nil {
+		return uuid.Nil, classify(err)
+	}
+
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, ErrTokenMalformed
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrTokenInvalidSubject, err)
+	}
+	return userID, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key in the ring, sorted
+// by key ID. HMAC secrets are left out.
+func (kr *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(kr.keys))}
+	for _, key := range kr.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	slices.SortFunc(set.Keys, func(a, b JWK) int { return strings.Compare(a.Kid, b.Kid) })
+	return set
+}
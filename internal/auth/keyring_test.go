@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newEd25519Key(t *testing.T, id string) (*Key, *Key) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+
+	private, err := ParseKey(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParseKey(id, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private, public
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldPriv, oldPub := newEd25519Key(t, "2024")
+	newPriv, _ := newEd25519Key(t, "2025")
+	userID := uuid.New()
+
+	before, err := NewKeyring([]*Key{oldPriv}, "2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.MakeJWT(userID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// after the rotation the old key is verify-only
+	after, err := NewKeyring([]*Key{oldPub, newPriv}, "2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := after.MakeJWT(userID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"old key": oldToken, "new key": newToken} {
+		got, err := after.ValidateJWT(token)
+		if err != nil || got != userID {
+			t.Errorf("%s: ValidateJWT() = %v, %v, want %v", name, got, err, userID)
+		}
+	}
+
+	if _, err := before.ValidateJWT(newToken); err == nil {
+		t.Error("ValidateJWT() accepted a token signed with a key outside the ring")
+	}
+	if _, err := NewKeyring([]*Key{oldPub}, "2024"); err == nil {
+		t.Error("NewKeyring() accepted a verify-only signing key")
+	}
+}
+
+func TestKeyringRejectsHMACTokens(t *testing.T) {
+	_, pub := newEd25519Key(t, "2025")
+	priv, _ := newEd25519Key(t, "other")
+	kr, err := NewKeyring([]*Key{pub, priv}, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an attacker who knows the public key tries it as an HMAC secret
+	forged, err := MakeJWT(uuid.New(), string(pub.verifyKey.(ed25519.PublicKey)), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.ValidateJWT(forged); err == nil {
+		t.Error("ValidateJWT() accepted an HS256 token")
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	edPriv, _ := newEd25519Key(t, "ed")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := ParseKey("rsa", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err := NewKeyring([]*Key{rsaPriv, edPriv}, "rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := kr.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() returned %d keys, want 2", len(set.Keys))
+	}
+	if k := set.Keys[0]; k.Kid != "ed" || k.Kty != "OKP" || k.Alg != "EdDSA" || k.X == "" {
+		t.Errorf("JWKS()[0] = %+v", k)
+	}
+	if k := set.Keys[1]; k.Kid != "rsa" || k.Kty != "RSA" || k.Alg != "RS256" || k.E != "AQAB" {
+		t.Errorf("JWKS()[1] = %+v", k)
+	}
+
+	if got := NewHMACKeyring("secret").JWKS(); len(got.Keys) != 0 {
+		t.Errorf("HMAC JWKS() = %+v, want no keys", got)
+	}
+}
+
+func TestKeyringValidation(t *testing.T) {
+	priv, _ := newEd25519Key(t, "k1")
+	signer, err := NewKeyring([]*Key{priv}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.Validation = Validation{Issuer: "chirpy", Audience: "chirpy-api"}
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		validation Validation
+		wantErr    error
+	}{
+		{
+			name:       "valid",
+			expiresIn:  time.Minute,
+			validation: Validation{Issuer: "chirpy", Audience: "chirpy-api", Algorithms: []string{"EdDSA"}},
+		},
+		{
+			name:       "expired",
+			expiresIn:  -time.Minute,
+			validation: Validation{Issuer: "chirpy"},
+			wantErr:    ErrTokenExpired,
+		},
+		{
+			name:       "expired within leeway",
+			expiresIn:  -time.Second,
+			validation: Validation{Issuer: "chirpy", Leeway: time.Minute},
+		},
+		{
+			name:       "wrong issuer",
+			expiresIn:  time.Minute,
+			validation: Validation{Issuer: "someone-else"},
+			wantErr:    ErrTokenWrongIssuer,
+		},
+		{
+			name:       "wrong audience",
+			expiresIn:  time.Minute,
+			validation: Validation{Issuer: "chirpy", Audience: "billing"},
+			wantErr:    ErrTokenWrongAudience,
+		},
+		{
+			name:       "algorithm not allowed",
+			expiresIn:  time.Minute,
+			validation: Validation{Issuer: "chirpy", Algorithms: []string{"RS256"}},
+			wantErr:    ErrTokenAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := signer.MakeJWT(userID, tt.expiresIn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verifier, _ := NewKeyring([]*Key{priv}, "k1")
+			verifier.Validation = tt.validation
+			got, err := verifier.ValidateJWT(token)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ValidateJWT() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != userID {
+				t.Errorf("ValidateJWT() = %v, want %v", got, userID)
+			}
+		})
+	}
+
+	// another token's signature on this token's claims
+	a, _ := signer.MakeJWT(userID, time.Minute)
+	b, _ := signer.MakeJWT(uuid.New(), time.Minute)
+	forged := a[:strings.LastIndex(a, ".")] + b[strings.LastIndex(b, "."):]
+	if _, err := signer.ValidateJWT(forged); !errors.Is(err, ErrTokenBadSignature) {
+		t.Errorf("ValidateJWT() on a forged token error = %v, want %v", err, ErrTokenBadSignature)
+	}
+}
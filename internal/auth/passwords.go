@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// PasswordHasher hashes passwords with Argon2id using Params, and tells
+// callers when a stored hash was made with weaker settings so they can
+// replace it while they have the plaintext password at hand (at login).
+//
+// Raising Params therefore upgrades everyone who logs in afterwards;
+// lowering them leaves existing hashes alone.
+type PasswordHasher struct {
+	Params argon2id.Params
+}
+
+// NewPasswordHasher returns a PasswordHasher that hashes with params.
+func NewPasswordHasher(params argon2id.Params) (*PasswordHasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return nil, fmt.Errorf("argon2id memory must be at least 8 KiB per thread, got %d KiB for %d threads", params.Memory, params.Parallelism)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return nil, fmt.Errorf("argon2id iterations and parallelism must be at least 1")
+	}
+	if params.SaltLength < 16 || params.KeyLength < 16 {
+		return nil, fmt.Errorf("argon2id salt and key must be at least 16 bytes")
+	}
+	return &PasswordHasher{Params: params}, nil
+}
+
+// Hash is HashPassword with h.Params.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	return argon2id.CreateHash(password, &h.Params)
+}
+
+// Check is CheckPasswordHash, also reporting whether the hash should be
+// replaced by h.Hash(password). That's only ever true when it matched.
+func (h *PasswordHasher) Check(password, hash string) (match, rehash bool, err error) {
+	match, params, err := argon2id.CheckHash(password, hash)
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, h.weaker(params), nil
+}
+
+// weaker reports whether a hash made with p is cheaper to crack than one
+// made with h.Params. Parallelism isn't compared: it splits the same work
+// across threads rather than adding any, and DefaultParams sets it to the
+// CPU count, so comparing it would rehash whenever the machine changes.
+func (h *PasswordHasher) weaker(p *argon2id.Params) bool {
+	return p.Memory < h.Params.Memory ||
+		p.Iterations < h.Params.Iterations ||
+		p.SaltLength < h.Params.SaltLength ||
+		p.KeyLength < h.Params.KeyLength
+}
+
+// maxTuneIterations stops TunePasswordParams on machines so fast it
+// would otherwise keep going for a long time.
+const maxTuneIterations = 64
+
+// TunePasswordParams benchmarks Argon2id on this machine and returns base
+// with the fewest iterations that take at least target per hash. Memory
+// and parallelism are left as they are: set memory as high as the server
+// can afford for concurrent logins first, then let this pick iterations.
+//
+// Run it on the hardware that will serve logins; the result is only as
+// good as the machine it's measured on.
+func TunePasswordParams(base argon2id.Params, target time.Duration) (argon2id.Params, time.Duration, error) {
+	return tunePasswordParams(base, target, func(p argon2id.Params) (time.Duration, error) {
+		start := time.Now()
+		_, err := argon2id.CreateHash("correct horse battery staple", &p)
+		return time.Since(start), err
+	})
+}
+
+func tunePasswordParams(base argon2id.Params, target time.Duration, measure func(argon2id.Params) (time.Duration, error)) (argon2id.Params, time.Duration, error) {
+	params := base
+	for params.Iterations = max(base.Iterations, 1); ; params.Iterations++ {
+		took, err := measure(params)
+		if err != nil {
+			return base, 0, err
+		}
+		if took >= target || params.Iterations >= maxTuneIterations {
+			return params, took, nil
+		}
+	}
+}
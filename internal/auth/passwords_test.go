@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// cheap keeps the tests fast; real deployments use far more memory.
+var cheap = argon2id.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasherCheck(t *testing.T) {
+	old, err := NewPasswordHasher(cheap)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher() error %v", err)
+	}
+	hash, err := old.Hash("hunter22")
+	if err != nil {
+		t.Fatalf("Hash() error %v", err)
+	}
+
+	stronger := cheap
+	stronger.Iterations = 2
+	moreThreads := cheap
+	moreThreads.Parallelism = 4
+
+	tests := []struct {
+		name       string
+		params     argon2id.Params
+		password   string
+		wantMatch  bool
+		wantRehash bool
+	}{
+		{name: "same params", params: cheap, password: "hunter22", wantMatch: true},
+		{name: "stronger params", params: stronger, password: "hunter22", wantMatch: true, wantRehash: true},
+		{name: "only parallelism differs", params: moreThreads, password: "hunter22", wantMatch: true},
+		{name: "wrong password", params: stronger, password: "hunter23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &PasswordHasher{Params: tt.params}
+			match, rehash, err := h.Check(tt.password, hash)
+			if err != nil {
+				t.Fatalf("Check() error %v", err)
+			}
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("Check() = %v, %v, want %v, %v", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherRejectsWeakParams(t *testing.T) {
+	bad := cheap
+	bad.SaltLength = 8
+	if _, err := NewPasswordHasher(bad); err == nil {
+		t.Error("NewPasswordHasher() with an 8 byte salt: expected an error")
+	}
+}
+
+func TestTunePasswordParams(t *testing.T) {
+	// pretend each iteration takes 40ms
+	measure := func(p argon2id.Params) (time.Duration, error) {
+		return time.Duration(p.Iterations) * 40 * time.Millisecond, nil
+	}
+
+	params, took, err := tunePasswordParams(cheap, 100*time.Millisecond, measure)
+	if err != nil {
+		t.Fatalf("tunePasswordParams() error %v", err)
+	}
+	if params.Iterations != 3 || took != 120*time.Millisecond {
+		t.Errorf("tunePasswordParams() = %d iterations in %v, want 3 in 120ms", params.Iterations, took)
+	}
+	if params.Memory != cheap.Memory {
+		t.Errorf("tunePasswordParams() changed memory to %d", params.Memory)
+	}
+
+	params, _, _ = tunePasswordParams(cheap, time.Hour, measure)
+	if params.Iterations != maxTuneIterations {
+		t.Errorf("tunePasswordParams() = %d iterations, want the cap of %d", params.Iterations, maxTuneIterations)
+	}
+}
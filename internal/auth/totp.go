@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// TOTP (RFC 6238) turns a shared secret and the current time into a 6 digit
+// code that changes every TOTPPeriod. The user's authenticator app and the
+// server both know the secret, so a correct code proves the user has the
+// device, not just the password.
+const TOTPPeriod = 30 * time.Second
+
+// totpSkew is how many steps either side of now we accept, to allow for a
+// phone clock that's a little off or a code typed just as it rolled over.
+const totpSkew = 1
+
+var totpOpts = totp.ValidateOpts{
+	Period:    uint(TOTPPeriod / time.Second),
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1, // what every authenticator app supports
+}
+
+// TOTPEnrollment is what a user needs to add Chirpy to their authenticator.
+type TOTPEnrollment struct {
+	Secret string // base32, for typing in by hand
+	URI    string // otpauth://totp/... with the secret, for QR codes
+	QRCode []byte // URI as a PNG QR code
+}
+
+// NewTOTPEnrollment generates a fresh secret for account (the user's email)
+// under issuer, the name the authenticator app shows.
+func NewTOTPEnrollment(issuer, account string) (TOTPEnrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: account,
+		Period:      totpOpts.Period,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	return TOTPEnrollment{Secret: key.Secret(), URI: key.URL(), QRCode: buf.Bytes()}, nil
+}
+
+// ValidateTOTP checks code against secret at time now. It returns the time
+// step the code belongs to, so the caller can refuse to accept a code from
+// the same step (or an earlier one) twice.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		t := now.Add(time.Duration(skew) * TOTPPeriod)
+		want, err := totp.GenerateCodeCustom(secret, t, totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return TOTPStep(t), true
+		}
+	}
+	return 0, false
+}
+
+// TOTPStep is the number of TOTP periods since the Unix epoch at t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// GenerateRecoveryCodes returns n single-use codes like "k3xq7-mz2pd" for
+// when the user loses their authenticator. Store only their hashes.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 7) // 50 bits, plenty for something only tried a few times
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage and lookup, ignoring
+// case, spaces and dashes in what the user typed. Codes are random enough
+// that a fast hash is fine, unlike passwords.
+func HashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	return HashToken(normalized)
+}
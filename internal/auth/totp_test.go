@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	enrollment, err := NewTOTPEnrollment("Chirpy", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enrollment.QRCode) == 0 || enrollment.Secret == "" {
+		t.Fatalf("NewTOTPEnrollment() = %+v", enrollment)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name   string
+		at     time.Time
+		wantOK bool
+	}{
+		{"current step", now, true},
+		{"previous step", now.Add(-TOTPPeriod), true},
+		{"next step", now.Add(TOTPPeriod), true},
+		{"too old", now.Add(-3 * TOTPPeriod), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := totp.GenerateCodeCustom(enrollment.Secret, tt.at, totpOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, ok := ValidateTOTP(enrollment.Secret, code, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateTOTP() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && step != TOTPStep(tt.at) {
+				t.Errorf("ValidateTOTP() step = %d, want %d", step, TOTPStep(tt.at))
+			}
+		})
+	}
+
+	if _, ok := ValidateTOTP(enrollment.Secret, "000000x", now); ok {
+		t.Error("ValidateTOTP() accepted a malformed code")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("GenerateRecoveryCodes() code %q has the wrong format", code)
+		}
+		if seen[code] {
+			t.Errorf("GenerateRecoveryCodes() repeated %q", code)
+		}
+		seen[code] = true
+	}
+
+	typed := " " + strings.ToUpper(strings.Replace(codes[0], "-", " ", 1)) + " "
+	if HashRecoveryCode(typed) != HashRecoveryCode(codes[0]) {
+		t.Errorf("HashRecoveryCode(%q) doesn't match HashRecoveryCode(%q)", typed, codes[0])
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors returned by Keyring.ValidateJWT. Each one wraps the underlying
+// reason, so check them with errors.Is.
+var (
+	ErrTokenMalformed      = errors.New("token is malformed")
+	ErrTokenExpired        = errors.New("token has expired")
+	ErrTokenNotYetValid    = errors.New("token is not valid yet")
+	ErrTokenBadSignature   = errors.New("token signature is invalid")
+	ErrTokenUnknownKey     = errors.New("token is signed with an unknown key")
+	ErrTokenAlgorithm      = errors.New("token signing algorithm is not allowed")
+	ErrTokenWrongIssuer    = errors.New("token has the wrong issuer")
+	ErrTokenWrongAudience  = errors.New("token has the wrong audience")
+	ErrTokenInvalidSubject = errors.New("token subject is not a user ID")
+)
+
+// DefaultIssuer is the "iss" claim Chirpy puts in its tokens.
+const DefaultIssuer = "chirpy"
+
+// Validation is what a token needs besides a good signature to be accepted.
+//
+// The signature check alone isn't enough: a token minted by another service
+// sharing our keys (or for another service, by us) would still verify. The
+// issuer and audience say who made the token and who it's meant for.
+type Validation struct {
+	// Algorithms the "alg" header may name. Whatever it says, a token still
+	// has to use the algorithm of the key its "kid" points to. Empty allows
+	// every algorithm of a key in the ring.
+	Algorithms []string
+	// Issuer is put in "iss" when signing and required when validating.
+	Issuer string
+	// Audience is put in "aud" when signing and, when set, required when
+	// validating. Empty means tokens aren't scoped to an audience.
+	Audience string
+	// Leeway is how far our clock and the signer's may drift apart when
+	// checking "exp", "nbf" and "iat".
+	Leeway time.Duration
+}
+
+// DefaultValidation only pins the issuer.
+var DefaultValidation = Validation{Issuer: DefaultIssuer}
+
+// parserOptions covers the claims. The algorithm is checked in the keyfunc
+// instead, since jwt.WithValidMethods reports a disallowed one as a bad
+// signature.
+func (v Validation) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.Leeway),
+		jwt.WithIssuer(v.Issuer),
+	}
+	if v.Audience != "" {
+		opts = append(opts, jwt.WithAudience(v.Audience))
+	}
+	return opts
+}
+
+func (v Validation) allows(alg string) bool {
+	return len(v.Algorithms) == 0 || slices.Contains(v.Algorithms, alg)
+}
+
+// classify turns the jwt package's errors into ours, so callers don't need
+// to know which library we use.
+func classify(err error) error {
+	var kind error
+	switch {
+	// our keyfunc's own errors come back wrapped in jwt.ErrTokenUnverifiable
+	case errors.Is(err, ErrTokenUnknownKey):
+		kind = ErrTokenUnknownKey
+	case errors.Is(err, ErrTokenAlgorithm):
+		kind = ErrTokenAlgorithm
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		kind = ErrTokenBadSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		kind = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		kind = ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		kind = ErrTokenWrongIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		kind = ErrTokenWrongAudience
+	default:
+		kind = ErrTokenMalformed
+	}
+	return fmt.Errorf("%w: %v", kind, err)
+}
@@ -0,0 +1,54 @@
+// Package billing turns payment providers' webhooks into the handful of
+// subscription events Chirpy cares about, whichever provider sent them.
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Action is what an event means for the user's subscription.
+type Action string
+
+const (
+	ActionNone          Action = ""                      // nothing for Chirpy to do
+	ActionStarted       Action = "subscription.started"  // the user subscribed
+	ActionRenewed       Action = "subscription.renewed"  // a new period was paid for
+	ActionPaymentFailed Action = "payment.failed"        // a payment didn't go through
+	ActionCanceled      Action = "subscription.canceled" // the subscription ended
+)
+
+// Event is one webhook delivery, parsed and mapped to an Action.
+type Event struct {
+	ID   string // unique per provider, retried deliveries repeat it; empty if the provider doesn't say
+	Type string // the provider's own name for the event
+
+	Action           Action
+	UserID           uuid.UUID
+	Plan             string    // empty if the event doesn't say
+	CurrentPeriodEnd time.Time // zero if the event doesn't say
+	OccurredAt       time.Time // when the provider created the event, zero if it doesn't say
+}
+
+var (
+	// ErrUnauthenticated wraps every reason Verify rejects a delivery.
+	ErrUnauthenticated = errors.New("billing: webhook not authenticated")
+	// ErrMalformed wraps every reason Parse rejects a payload.
+	ErrMalformed = errors.New("billing: malformed webhook payload")
+)
+
+// Provider is one payment provider's webhook format.
+type Provider interface {
+	// Name identifies the provider in URLs and in the webhook_events log.
+	Name() string
+	// Verify checks the delivery with these headers and raw body really
+	// came from the provider.
+	Verify(header http.Header, body []byte) error
+	// Parse reads an event from a raw body that passed Verify. It's also
+	// used to re-read stored events, so it mustn't depend on anything but
+	// the body.
+	Parse(body []byte) (Event, error)
+}
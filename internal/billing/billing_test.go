@@ -0,0 +1,145 @@
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/webhook"
+)
+
+var userID = uuid.MustParse("3311741c-680c-4546-99f3-fc9efac2036c")
+
+func TestPolkaVerify(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	signatures, err := webhook.NewVerifier("polka-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		provider Polka
+		header   http.Header
+		wantErr  bool
+	}{
+		{name: "api key", provider: Polka{APIKey: "key"}, header: http.Header{"Authorization": {"ApiKey key"}}},
+		{name: "wrong api key", provider: Polka{APIKey: "key"}, header: http.Header{"Authorization": {"ApiKey other"}}, wantErr: true},
+		{name: "no api key", provider: Polka{APIKey: "key"}, header: http.Header{}, wantErr: true},
+		{
+			name:     "signature",
+			provider: Polka{Signatures: signatures},
+			header:   http.Header{"Polka-Signature": {webhook.Sign("polka-secret", time.Now(), body)}},
+		},
+		{
+			name:     "api key when signatures are required",
+			provider: Polka{APIKey: "key", Signatures: signatures},
+			header:   http.Header{"Authorization": {"ApiKey key"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.provider.Verify(tt.header, body)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Verify() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("Verify() error = %v, want ErrUnauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestPolkaParse(t *testing.T) {
+	event, err := Polka{}.Parse([]byte(`{"id":"evt_1","event":"subscription.renewed","created_at":"2025-01-01T00:00:00Z","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c","plan":"red","current_period_end":"2025-02-01T00:00:00Z"}}`))
+	if err != nil {
+		t.Fatalf("Parse() error %v", err)
+	}
+	want := Event{
+		ID:               "evt_1",
+		Type:             "subscription.renewed",
+		Action:           ActionRenewed,
+		UserID:           userID,
+		Plan:             "red",
+		CurrentPeriodEnd: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		OccurredAt:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if event != want {
+		t.Errorf("Parse() = %+v, want %+v", event, want)
+	}
+
+	// a repeated upgrade looks just like a retried one, so neither gets an ID
+	event, err = Polka{}.Parse([]byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`))
+	if err != nil || event.ID != "" {
+		t.Errorf("Parse(no id) = %+v, %v, want an empty ID", event, err)
+	}
+
+	event, err = Polka{}.Parse([]byte(`{"event":"user.renamed","data":{}}`))
+	if err != nil || event.Action != ActionNone {
+		t.Errorf("Parse(unknown event) = %+v, %v, want ActionNone", event, err)
+	}
+
+	if _, err := (Polka{}).Parse([]byte(`{"data":{}}`)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parse(no event) error = %v, want ErrMalformed", err)
+	}
+}
+
+func TestSignedJSON(t *testing.T) {
+	signatures, err := webhook.NewVerifier("whsec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := SignedJSON{ProviderName: "stripe", Signatures: signatures}
+	body := []byte(`{
+		"id": "evt_1",
+		"type": "invoice.paid",
+		"created": 1735689600,
+		"data": {"object": {
+			"metadata": {"user_id": "3311741c-680c-4546-99f3-fc9efac2036c"},
+			"plan": {"id": "red", "interval": "month"},
+			"current_period_end": 1738368000
+		}}
+	}`)
+
+	header := http.Header{"Stripe-Signature": {webhook.Sign("whsec", time.Now(), body)}}
+	if err := provider.Verify(header, body); err != nil {
+		t.Errorf("Verify() error %v", err)
+	}
+	if err := provider.Verify(header, []byte(`{}`)); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Verify(changed body) error = %v, want ErrUnauthenticated", err)
+	}
+	custom := provider
+	custom.Header = "Billing-Signature"
+	if err := custom.Verify(header, body); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Verify(signature in the wrong header) error = %v, want ErrUnauthenticated", err)
+	}
+
+	event, err := provider.Parse(body)
+	if err != nil {
+		t.Fatalf("Parse() error %v", err)
+	}
+	want := Event{
+		ID:               "evt_1",
+		Type:             "invoice.paid",
+		Action:           ActionRenewed,
+		UserID:           userID,
+		Plan:             "red",
+		CurrentPeriodEnd: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		OccurredAt:       time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if event != want {
+		t.Errorf("Parse() = %+v, want %+v", event, want)
+	}
+
+	event, err = provider.Parse([]byte(`{"id":"evt_2","type":"customer.subscription.deleted","data":{"object":{"plan":"red"}}}`))
+	if err != nil || event.Action != ActionCanceled || event.Plan != "red" {
+		t.Errorf("Parse(plan as string) = %+v, %v", event, err)
+	}
+
+	if _, err := provider.Parse([]byte(`{"type":"invoice.paid"}`)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parse(no id) error = %v, want ErrMalformed", err)
+	}
+}
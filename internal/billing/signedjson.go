@@ -0,0 +1,109 @@
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grainme/Chirpy/internal/webhook"
+)
+
+// SignedJSON is a generic provider for Stripe-style webhooks: every
+// delivery is signed in the format webhook.Verifier checks, and looks like
+//
+//	{
+//	  "id": "evt_1",
+//	  "type": "invoice.paid",
+//	  "created": 1733011200,
+//	  "data": {"object": {
+//	    "metadata": {"user_id": "..."},
+//	    "plan": "red",
+//	    "current_period_end": 1735689600
+//	  }}
+//	}
+//
+// where created and current_period_end are in Unix seconds and plan may also be an
+// object with an "id", as Stripe sends it. The user is whoever was put
+// in the subscription's metadata when it was created at the provider.
+type SignedJSON struct {
+	ProviderName string
+	Header       string // carrying the signature, DefaultSignatureHeader if empty
+	Signatures   *webhook.Verifier
+}
+
+// DefaultSignatureHeader carries SignedJSON signatures unless told otherwise.
+const DefaultSignatureHeader = "Stripe-Signature"
+
+var signedJSONActions = map[string]Action{
+	"customer.subscription.created": ActionStarted,
+	"invoice.paid":                  ActionRenewed,
+	"invoice.payment_failed":        ActionPaymentFailed,
+	"customer.subscription.deleted": ActionCanceled,
+}
+
+func (p SignedJSON) Name() string {
+	return p.ProviderName
+}
+
+func (p SignedJSON) Verify(header http.Header, body []byte) error {
+	name := p.Header
+	if name == "" {
+		name = DefaultSignatureHeader
+	}
+	if p.Signatures == nil {
+		return fmt.Errorf("%w: no signing secret configured", ErrUnauthenticated)
+	}
+	if err := p.Signatures.Verify(header.Get(name), body); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	return nil
+}
+
+func (p SignedJSON) Parse(body []byte) (Event, error) {
+	var payload struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Created int64  `json:"created"`
+		Data    struct {
+			Object struct {
+				Metadata struct {
+					UserID uuid.UUID `json:"user_id"`
+				} `json:"metadata"`
+				Plan             json.RawMessage `json:"plan"`
+				CurrentPeriodEnd int64           `json:"current_period_end"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if payload.ID == "" || payload.Type == "" {
+		return Event{}, fmt.Errorf("%w: id and type are required", ErrMalformed)
+	}
+
+	object := payload.Data.Object
+	event := Event{
+		ID:     payload.ID,
+		Type:   payload.Type,
+		Action: signedJSONActions[payload.Type],
+		UserID: object.Metadata.UserID,
+	}
+	if len(object.Plan) > 0 && json.Unmarshal(object.Plan, &event.Plan) != nil {
+		var plan struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(object.Plan, &plan); err != nil {
+			return Event{}, fmt.Errorf("%w: plan is neither a string nor an object", ErrMalformed)
+		}
+		event.Plan = plan.ID
+	}
+	if object.CurrentPeriodEnd != 0 {
+		event.CurrentPeriodEnd = time.Unix(object.CurrentPeriodEnd, 0).UTC()
+	}
+	if payload.Created != 0 {
+		event.OccurredAt = time.Unix(payload.Created, 0).UTC()
+	}
+	return event, nil
+}
@@ -7,25 +7,52 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const chirpHasReplies = `-- name: ChirpHasReplies :one
+SELECT
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirps
+    WHERE
+      parent_id = $1::uuid
+  )
+`
+
+func (q *Queries) ChirpHasReplies(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpHasReplies, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createChirp = `-- name: CreateChirp :one
 INSERT INTO
-  chirps (id, created_at, updated_at, body, user_id)
+  chirps (id, created_at, updated_at, body, user_id, parent_id)
 VALUES
-  ($1, NOW(), NOW(), $2, $3) RETURNING id, created_at, updated_at, body, user_id
+  ($1, NOW(), NOW(), $2, $3, $4) RETURNING id, created_at, updated_at, body, user_id, parent_id, deleted_at, search_vector
 `
 
 type CreateChirpParams struct {
-	ID     uuid.UUID
-	Body   string
-	UserID uuid.UUID
+	ID       uuid.UUID
+	Body     string
+	UserID   uuid.UUID
+	ParentID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.ID, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ID,
+		arg.Body,
+		arg.UserID,
+		arg.ParentID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,6 +60,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -48,30 +78,107 @@ func (q *Queries) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE
+  ancestors AS (
+    SELECT
+      parent.id,
+      parent.created_at,
+      parent.updated_at,
+      parent.body,
+      parent.user_id,
+      parent.parent_id,
+      parent.deleted_at,
+      1 AS depth
+    FROM
+      chirps child
+      INNER JOIN chirps parent ON parent.id = child.parent_id
+    WHERE
+      child.id = $1
+    UNION ALL
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      ancestors.depth + 1
+    FROM
+      chirps
+      INNER JOIN ancestors ON chirps.id = ancestors.parent_id
+  )
 SELECT
-  id, created_at, updated_at, body, user_id
+  id,
+  created_at,
+  updated_at,
+  body,
+  user_id,
+  parent_id,
+  deleted_at,
+  depth,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = ancestors.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = ancestors.id
+      AND chirp_likes.user_id = $2::uuid
+  ) AS liked_by_me
 FROM
-  chirps
+  ancestors
 ORDER BY
-  created_at ASC
+  depth DESC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetChirpAncestorsParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+type GetChirpAncestorsRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	Depth     int32
+	LikeCount int64
+	LikedByMe bool
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, arg GetChirpAncestorsParams) ([]GetChirpAncestorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, arg.ID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetChirpAncestorsRow
 	for rows.Next() {
-		var i Chirp
+		var i GetChirpAncestorsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.Depth,
+			&i.LikeCount,
+			&i.LikedByMe,
 		); err != nil {
 			return nil, err
 		}
@@ -88,7 +195,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 
 const getChirpById = `-- name: GetChirpById :one
 SELECT
-  id, created_at, updated_at, body, user_id
+  id, created_at, updated_at, body, user_id, parent_id, deleted_at, search_vector
 FROM
   chirps
 WHERE
@@ -104,34 +211,454 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpByIdForShare = `-- name: GetChirpByIdForShare :one
+SELECT
+  id, created_at, updated_at, body, user_id, parent_id, deleted_at, search_vector
+FROM
+  chirps
+WHERE
+  id = $1 FOR SHARE
+`
+
+// Locks the chirp against being deleted while a reply to it is added.
+func (q *Queries) GetChirpByIdForShare(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdForShare, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
-const getChirpByUserId = `-- name: GetChirpByUserId :many
+const getChirpByIdForUpdate = `-- name: GetChirpByIdForUpdate :one
 SELECT
-  id, created_at, updated_at, body, user_id
+  id, created_at, updated_at, body, user_id, parent_id, deleted_at, search_vector
 FROM
   chirps
 WHERE
-  user_id = $1
+  id = $1 FOR UPDATE
 `
 
-func (q *Queries) GetChirpByUserId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpByUserId, userID)
+// Locks the chirp so no reply can be added to it until it's been deleted
+// or tombstoned.
+func (q *Queries) GetChirpByIdForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIdForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpReplies = `-- name: GetChirpReplies :many
+WITH RECURSIVE
+  replies AS (
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      1 AS depth
+    FROM
+      chirps
+    WHERE
+      chirps.parent_id = $1
+    UNION ALL
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      replies.depth + 1
+    FROM
+      chirps
+      INNER JOIN replies ON chirps.parent_id = replies.id
+    WHERE
+      replies.depth < $2::int
+  )
+SELECT
+  id,
+  created_at,
+  updated_at,
+  body,
+  user_id,
+  parent_id,
+  deleted_at,
+  depth,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = replies.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = replies.id
+      AND chirp_likes.user_id = $3::uuid
+  ) AS liked_by_me
+FROM
+  replies
+ORDER BY
+  created_at ASC,
+  id ASC
+`
+
+type GetChirpRepliesParams struct {
+	ID       uuid.UUID
+	MaxDepth int32
+	ViewerID uuid.NullUUID
+}
+
+type GetChirpRepliesRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	ParentID  uuid.NullUUID
+	DeletedAt sql.NullTime
+	Depth     int32
+	LikeCount int64
+	LikedByMe bool
+}
+
+func (q *Queries) GetChirpReplies(ctx context.Context, arg GetChirpRepliesParams) ([]GetChirpRepliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpReplies, arg.ID, arg.MaxDepth, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Chirp
+	var items []GetChirpRepliesRow
 	for rows.Next() {
-		var i Chirp
+		var i GetChirpRepliesRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ParentID,
+			&i.DeletedAt,
+			&i.Depth,
+			&i.LikeCount,
+			&i.LikedByMe,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.deleted_at, chirps.search_vector,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = $1::uuid
+  ) AS liked_by_me
+FROM
+  chirps
+WHERE
+  deleted_at IS NULL
+  AND (
+    $2::uuid IS NULL
+    OR user_id = $2
+  )
+  AND (
+    $3::timestamp IS NULL
+    OR (created_at, id) > (
+      $3::timestamp,
+      $4::uuid
+    )
+  )
+ORDER BY
+  created_at ASC,
+  id ASC
+LIMIT
+  $5
+`
+
+type ListChirpsAscParams struct {
+	ViewerID        uuid.NullUUID
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+type ListChirpsAscRow struct {
+	Chirp     Chirp
+	LikeCount int64
+	LikedByMe bool
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]ListChirpsAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpsAscRow
+	for rows.Next() {
+		var i ListChirpsAscRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ParentID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.SearchVector,
+			&i.LikeCount,
+			&i.LikedByMe,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.deleted_at, chirps.search_vector,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = $1::uuid
+  ) AS liked_by_me
+FROM
+  chirps
+WHERE
+  deleted_at IS NULL
+  AND (
+    $2::uuid IS NULL
+    OR user_id = $2
+  )
+  AND (
+    $3::timestamp IS NULL
+    OR (created_at, id) < (
+      $3::timestamp,
+      $4::uuid
+    )
+  )
+ORDER BY
+  created_at DESC,
+  id DESC
+LIMIT
+  $5
+`
+
+type ListChirpsDescParams struct {
+	ViewerID        uuid.NullUUID
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+type ListChirpsDescRow struct {
+	Chirp     Chirp
+	LikeCount int64
+	LikedByMe bool
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]ListChirpsDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.ViewerID,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpsDescRow
+	for rows.Next() {
+		var i ListChirpsDescRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ParentID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.SearchVector,
+			&i.LikeCount,
+			&i.LikedByMe,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.deleted_at, chirps.search_vector,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = $1
+  ) AS liked_by_me
+FROM
+  chirps
+  INNER JOIN follows ON follows.followee_id = chirps.user_id
+WHERE
+  follows.follower_id = $1
+  AND chirps.deleted_at IS NULL
+  AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (
+      $2::timestamp,
+      $3::uuid
+    )
+  )
+ORDER BY
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  $4
+`
+
+type ListTimelineParams struct {
+	FollowerID      uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+type ListTimelineRow struct {
+	Chirp     Chirp
+	LikeCount int64
+	LikedByMe bool
+}
+
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]ListTimelineRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.FollowerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTimelineRow
+	for rows.Next() {
+		var i ListTimelineRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ParentID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.SearchVector,
+			&i.LikeCount,
+			&i.LikedByMe,
 		); err != nil {
 			return nil, err
 		}
@@ -145,3 +672,230 @@ func (q *Queries) GetChirpByUserId(ctx context.Context, userID uuid.UUID) ([]Chi
 	}
 	return items, nil
 }
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT
+  chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.parent_id, chirps.deleted_at, chirps.search_vector,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = $1::uuid
+  ) AS liked_by_me,
+  ts_rank(chirps.search_vector, query)::real AS rank,
+  ts_headline(
+    'english',
+    replace(
+      replace(
+        replace(
+          replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'),
+          '>',
+          '&gt;'
+        ),
+        '"',
+        '&quot;'
+      ),
+      '''',
+      '&#39;'
+    ),
+    query,
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+  )::text AS snippet
+FROM
+  chirps,
+  websearch_to_tsquery('english', $2) AS query
+WHERE
+  chirps.search_vector @@ query
+  AND chirps.deleted_at IS NULL
+  AND (
+    $3::uuid IS NULL
+    OR chirps.user_id = $3
+  )
+  AND (
+    $4::timestamp IS NULL
+    OR chirps.created_at >= $4::timestamp
+  )
+  AND (
+    $5::timestamp IS NULL
+    OR chirps.created_at < $5::timestamp
+  )
+  AND (
+    $6::real IS NULL
+    OR (
+      ts_rank(chirps.search_vector, query)::real,
+      chirps.created_at,
+      chirps.id
+    ) < (
+      $6::real,
+      $7::timestamp,
+      $8::uuid
+    )
+  )
+ORDER BY
+  rank DESC,
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  $9
+`
+
+type SearchChirpsParams struct {
+	ViewerID        uuid.NullUUID
+	Query           string
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+type SearchChirpsRow struct {
+	Chirp     Chirp
+	LikeCount int64
+	LikedByMe bool
+	Rank      float32
+	Snippet   string
+}
+
+// Ranked full-text search. Pages are keyed on (rank, created_at, id), so the
+// cursor rank has to be the exact real ts_rank returned for the previous page.
+// The snippet is safe HTML: the body is escaped before matches are wrapped
+// in <mark> tags.
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.ViewerID,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ParentID,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.SearchVector,
+			&i.LikeCount,
+			&i.LikedByMe,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+WITH
+  purged_revisions AS (
+    DELETE FROM chirp_revisions
+    WHERE
+      chirp_id = $1
+  ),
+  purged_hashtags AS (
+    DELETE FROM chirp_hashtags
+    WHERE
+      chirp_id = $1
+  ),
+  purged_mentions AS (
+    DELETE FROM chirp_mentions
+    WHERE
+      chirp_id = $1
+  )
+UPDATE chirps
+SET
+  body = '',
+  updated_at = NOW(),
+  deleted_at = NOW()
+WHERE
+  id = $1
+`
+
+// Keeps the row so replies still have a parent, but drops everything the
+// author wrote, including the edit history, hashtags and mentions.
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+WITH
+  previous AS (
+    INSERT INTO
+      chirp_revisions (id, created_at, chirp_id, body)
+    SELECT
+      $1::uuid,
+      NOW(),
+      chirps.id,
+      chirps.body
+    FROM
+      chirps
+    WHERE
+      chirps.id = $2
+  )
+UPDATE chirps
+SET
+  body = $3,
+  updated_at = NOW()
+WHERE
+  chirps.id = $2 RETURNING id, created_at, updated_at, body, user_id, parent_id, deleted_at, search_vector
+`
+
+type UpdateChirpBodyParams struct {
+	RevisionID uuid.UUID
+	ID         uuid.UUID
+	Body       string
+}
+
+// Archives the current body into chirp_revisions in the same statement,
+// so a chirp can never be edited without leaving a revision behind.
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.RevisionID, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ParentID,
+		&i.DeletedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
@@ -11,8 +11,56 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const confirmEmail = `-- name: ConfirmEmail :one
+UPDATE users
+SET
+  email = $1,
+  pending_email = CASE
+    WHEN pending_email = $1 THEN NULL
+    ELSE pending_email
+  END,
+  email_verified_at = NOW(),
+  updated_at = NOW()
+WHERE
+  id = $2
+  AND (
+    email = $1
+    OR pending_email = $1
+  ) RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
+`
+
+type ConfirmEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+// Marks email verified for the user, switching to it first if it's their
+// pending email. No rows means it's neither their email nor pending.
+func (q *Queries) ConfirmEmail(ctx context.Context, arg ConfirmEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO
   users (
@@ -20,20 +68,27 @@ INSERT INTO
     created_at,
     updated_at,
     email,
-    hashed_password
+    hashed_password,
+    handle
   )
 VALUES
-  ($1, NOW(), NOW(), $2, $3) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  ($1, NOW(), NOW(), $2, $3, $4) RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
 `
 
 type CreateUserParams struct {
 	ID             uuid.UUID
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.ID, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.ID,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Handle,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -41,7 +96,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
@@ -55,9 +118,45 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_step = 0,
+  updated_at = NOW()
+WHERE
+  id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET
+  totp_enabled_at = NOW(),
+  totp_last_step = $2,
+  updated_at = NOW()
+WHERE
+  id = $1
+`
+
+type EnableTOTPParams struct {
+	ID           uuid.UUID
+	TotpLastStep int64
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, arg.ID, arg.TotpLastStep)
+	return err
+}
+
 const findUserById = `-- name: FindUserById :one
 SELECT
-  id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
 FROM
   users
 WHERE
@@ -73,14 +172,22 @@ func (q *Queries) FindUserById(ctx context.Context, id uuid.UUID) (User, error)
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT
-  id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
 FROM
   users
 WHERE
@@ -96,14 +203,22 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
 SELECT
-  id, users.created_at, users.updated_at, email, hashed_password, is_chirpy_red, token, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at
+  id, users.created_at, users.updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, token, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at, family_id, replaced_by, user_agent, ip_address
 FROM
   users
   INNER JOIN refresh_tokens ON refresh_tokens.user_id = users.id
@@ -112,18 +227,30 @@ WHERE
 `
 
 type GetUserFromRefreshTokenRow struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
-	Token          string
-	CreatedAt_2    time.Time
-	UpdatedAt_2    time.Time
-	UserID         uuid.UUID
-	ExpiresAt      time.Time
-	RevokedAt      sql.NullTime
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Handle          sql.NullString
+	DisplayName     string
+	Bio             string
+	AvatarUrl       string
+	TotpSecret      sql.NullString
+	TotpEnabledAt   sql.NullTime
+	TotpLastStep    int64
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	Token           string
+	CreatedAt_2     time.Time
+	UpdatedAt_2     time.Time
+	UserID          uuid.UUID
+	ExpiresAt       time.Time
+	RevokedAt       sql.NullTime
+	FamilyID        uuid.UUID
+	ReplacedBy      sql.NullString
+	UserAgent       string
+	IpAddress       string
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -135,34 +262,184 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 		&i.Token,
 		&i.CreatedAt_2,
 		&i.UpdatedAt_2,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+		&i.UserAgent,
+		&i.IpAddress,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT
+  users.id,
+  users.created_at,
+  users.handle,
+  users.display_name,
+  users.bio,
+  users.avatar_url,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      follows
+    WHERE
+      follows.followee_id = users.id
+  ) AS follower_count,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      follows
+    WHERE
+      follows.follower_id = users.id
+  ) AS following_count,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirps
+    WHERE
+      chirps.user_id = users.id
+      AND chirps.deleted_at IS NULL
+  ) AS chirp_count
+FROM
+  users
+WHERE
+  users.id = $1
+  OR users.handle = $2
+`
+
+type GetUserProfileParams struct {
+	ID     uuid.NullUUID
+	Handle sql.NullString
+}
+
+type GetUserProfileRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	FollowerCount  int64
+	FollowingCount int64
+	ChirpCount     int64
+}
+
+// Looks a user up by ID or by handle, whichever is given.
+func (q *Queries) GetUserProfile(ctx context.Context, arg GetUserProfileParams) (GetUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfile, arg.ID, arg.Handle)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT
+  id,
+  handle
+FROM
+  users
+WHERE
+  handle = ANY ($1::text[])
+`
+
+type GetUsersByHandlesRow struct {
+	ID     uuid.UUID
+	Handle sql.NullString
+}
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]GetUsersByHandlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByHandlesRow
+	for rows.Next() {
+		var i GetUsersByHandlesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rehashPassword = `-- name: RehashPassword :exec
 UPDATE users
 SET
-  email = $1,
-  hashed_password = $2
+  hashed_password = $1
 WHERE
-  id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  id = $2
+  AND hashed_password = $3
 `
 
-type UpdateUserParams struct {
-	Email          string
-	HashedPassword string
-	ID             uuid.UUID
+type RehashPasswordParams struct {
+	NewHash string
+	ID      uuid.UUID
+	OldHash string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+// Swaps in a stronger hash of the same password. It leaves updated_at
+// alone, and does nothing if the password changed since old_hash was read.
+func (q *Queries) RehashPassword(ctx context.Context, arg RehashPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashPassword, arg.NewHash, arg.ID, arg.OldHash)
+	return err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :one
+UPDATE users
+SET
+  pending_email = NULLIF($1::TEXT, email),
+  updated_at = NOW()
+WHERE
+  id = $2 RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
+`
+
+type SetPendingEmailParams struct {
+	PendingEmail string
+	ID           uuid.UUID
+}
+
+// Passing the current email cancels a pending change.
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setPendingEmail, arg.PendingEmail, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -170,21 +447,77 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
 
-const upgradeUser = `-- name: UpgradeUser :one
+const startTOTPEnrollment = `-- name: StartTOTPEnrollment :execrows
 UPDATE users
 SET
-  is_chirpy_red = true
+  totp_secret = $2,
+  updated_at = NOW()
 WHERE
-  id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+  id = $1
+  AND totp_enabled_at IS NULL
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUser, id)
+type StartTOTPEnrollmentParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+}
+
+// Replaces any unconfirmed secret; does nothing once 2FA is enabled.
+func (q *Queries) StartTOTPEnrollment(ctx context.Context, arg StartTOTPEnrollmentParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, startTOTPEnrollment, arg.ID, arg.TotpSecret)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+  hashed_password = COALESCE($1, hashed_password),
+  handle = COALESCE($2, handle),
+  display_name = COALESCE($3, display_name),
+  bio = COALESCE($4, bio),
+  avatar_url = COALESCE($5, avatar_url),
+  updated_at = NOW()
+WHERE
+  id = $6 RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email
+`
+
+type UpdateUserParams struct {
+	HashedPassword sql.NullString
+	Handle         sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
+	AvatarUrl      sql.NullString
+	ID             uuid.UUID
+}
+
+// Only the fields passed as non-NULL change, so callers can update the
+// profile without resending the password. Email changes go through
+// SetPendingEmail and ConfirmEmail instead.
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -192,7 +525,39 @@ func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
 	)
 	return i, err
 }
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET
+  totp_last_step = $2
+WHERE
+  id = $1
+  AND totp_last_step < $2
+`
+
+type UseTOTPStepParams struct {
+	ID           uuid.UUID
+	TotpLastStep int64
+}
+
+// Records the step of an accepted code. No rows means that step (or a later
+// one) was already used.
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
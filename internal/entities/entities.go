@@ -0,0 +1,129 @@
+// Package entities finds the structured bits of a chirp body, like #hashtags
+// and @mentions.
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Hashtags returns the distinct tags in body, lower-cased and without the
+// leading '#', in order of first appearance. The result is never nil, so it
+// can be handed straight to a query as a (possibly empty) text[].
+//
+// A tag starts with '#' at the beginning of the body or after a non-tag
+// character, runs over letters, digits and underscores, and needs at least
+// one letter: "#go", "#Go_1" and "(#chirpy)" are tags, "#1" and "a#b" aren't.
+func Hashtags(body string) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+
+	prev := rune(-1)
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		if r != '#' || (prev != -1 && isTagRune(prev)) {
+			prev = r
+			i += size
+			continue
+		}
+
+		start := i + size
+		end := start
+		hasLetter := false
+		for end < len(body) {
+			tr, tsize := utf8.DecodeRuneInString(body[end:])
+			if !isTagRune(tr) {
+				break
+			}
+			hasLetter = hasLetter || unicode.IsLetter(tr)
+			end += tsize
+		}
+
+		if hasLetter {
+			tag := strings.ToLower(body[start:end])
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+
+		prev = r
+		i = start
+	}
+	return tags
+}
+
+// NormalizeHashtag turns user input like "#Go" into the stored form "go".
+// It returns false when what's left isn't a valid tag.
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "#")
+	tags := Hashtags("#" + tag)
+	if len(tags) != 1 || tags[0] != strings.ToLower(tag) {
+		return "", false
+	}
+	return tags[0], true
+}
+
+// MaxHandleLength is the longest handle a user can pick.
+const MaxHandleLength = 30
+
+// Mention is an @handle found in a chirp body. Start and End are offsets in
+// Unicode code points (not bytes), End exclusive, and cover the '@' too.
+type Mention struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// Mentions returns every @handle in body, in order, with Handle lower-cased
+// and without the '@'. Like hashtags, a mention needs a non-handle character
+// (or nothing) before the '@', so "hi@example.com" isn't one.
+func Mentions(body string) []Mention {
+	var mentions []Mention
+
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isHandleRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isHandleRune(runes[end]) {
+			end++
+		}
+		// an overlong run isn't a handle anyone could have, don't link part of it
+		if n := end - i - 1; n > 0 && n <= MaxHandleLength {
+			mentions = append(mentions, Mention{
+				Handle: strings.ToLower(string(runes[i+1 : end])),
+				Start:  i,
+				End:    end,
+			})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+// NormalizeHandle lower-cases handle, dropping a leading '@', and checks it's
+// 1 to MaxHandleLength ASCII letters, digits or underscores.
+func NormalizeHandle(handle string) (string, bool) {
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	if handle == "" || len(handle) > MaxHandleLength {
+		return "", false
+	}
+	for _, r := range handle {
+		if !isHandleRune(r) {
+			return "", false
+		}
+	}
+	return handle, true
+}
+
+func isHandleRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -0,0 +1,129 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "no tags",
+			body: "just a regular chirp",
+			want: []string{},
+		},
+		{
+			name: "tags are lower-cased and deduplicated",
+			body: "#Go is great, #go is fun #GoLang",
+			want: []string{"go", "golang"},
+		},
+		{
+			name: "punctuation around tags",
+			body: "(#chirpy), #red! #under_score.",
+			want: []string{"chirpy", "red", "under_score"},
+		},
+		{
+			name: "needs a letter",
+			body: "we're #1 #2024 #v2",
+			want: []string{"v2"},
+		},
+		{
+			name: "mid-word hash isn't a tag",
+			body: "issue#12 c#sharp ##double",
+			want: []string{"double"},
+		},
+		{
+			name: "unicode",
+			body: "#Çörek #日本",
+			want: []string{"çörek", "日本"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Hashtags(tt.body)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Hashtags() expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   string
+		wantOk bool
+	}{
+		{tag: "Go", want: "go", wantOk: true},
+		{tag: "#Go", want: "go", wantOk: true},
+		{tag: "123", wantOk: false},
+		{tag: "two words", wantOk: false},
+		{tag: "", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := NormalizeHashtag(tt.tag)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("NormalizeHashtag() expected (%q, %v), got (%q, %v)", tt.want, tt.wantOk, got, ok)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Mention
+	}{
+		{
+			name: "no mentions",
+			body: "mail me at hi@example.com",
+			want: nil,
+		},
+		{
+			name: "offsets cover the at sign",
+			body: "hey @Alice and @bob_2!",
+			want: []Mention{
+				{Handle: "alice", Start: 4, End: 10},
+				{Handle: "bob_2", Start: 15, End: 21},
+			},
+		},
+		{
+			name: "offsets count code points",
+			body: "çörek @chef",
+			want: []Mention{
+				{Handle: "chef", Start: 6, End: 11},
+			},
+		},
+		{
+			name: "repeated mentions are all reported",
+			body: "@bob @bob",
+			want: []Mention{
+				{Handle: "bob", Start: 0, End: 4},
+				{Handle: "bob", Start: 5, End: 9},
+			},
+		},
+		{
+			name: "lone and overlong",
+			body: "@ @" + strings.Repeat("a", MaxHandleLength+1),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mentions(tt.body)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Mentions() expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
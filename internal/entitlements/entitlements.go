@@ -0,0 +1,104 @@
+// Package entitlements says what each user gets to do, so handlers ask it
+// rather than checking for Chirpy Red themselves.
+package entitlements
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Perks are the limits and features that come with a tier.
+type Perks struct {
+	Tier           string
+	MaxChirpLength int  // in characters
+	EditChirps     bool // edit their own chirps after posting them
+	ChirpRate      Rate // how fast they may post
+	VerifiedBadge  bool // shown on their chirps
+}
+
+// Rate allows Chirps per sliding window of Per.
+type Rate struct {
+	Chirps int
+	Per    time.Duration
+}
+
+var (
+	// Free is what every user gets.
+	Free = Perks{
+		Tier:           "free",
+		MaxChirpLength: 140,
+		ChirpRate:      Rate{Chirps: 30, Per: time.Hour},
+	}
+	// Red is what Chirpy Red subscribers get.
+	Red = Perks{
+		Tier:           "red",
+		MaxChirpLength: 1000,
+		EditChirps:     true,
+		ChirpRate:      Rate{Chirps: 300, Per: time.Hour},
+		VerifiedBadge:  true,
+	}
+)
+
+// RetryAfter says how long someone who has posted count chirps in the
+// current window, the first of them at oldest, must wait before posting
+// again. It's zero if they can post now.
+func (r Rate) RetryAfter(count int64, oldest, now time.Time) time.Duration {
+	if count < int64(r.Chirps) {
+		return 0
+	}
+	return max(oldest.Add(r.Per).Sub(now), time.Second)
+}
+
+// Store says which users are Chirpy Red right now. *database.Queries is one,
+// and its ListChirpyRedUsers is the only place that decides who is.
+type Store interface {
+	ListChirpyRedUsers(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error)
+}
+
+// Service looks up users' perks.
+type Service struct {
+	Store Store
+	Free  Perks
+	Red   Perks
+}
+
+// NewService gives users the Free and Red perks.
+func NewService(store Store) *Service {
+	return &Service{Store: store, Free: Free, Red: Red}
+}
+
+// For returns userID's perks.
+func (s *Service) For(ctx context.Context, userID uuid.UUID) (Perks, error) {
+	perks, err := s.ForUsers(ctx, []uuid.UUID{userID})
+	if err != nil {
+		return Perks{}, err
+	}
+	return perks[userID], nil
+}
+
+// IsRed reports whether userID is Chirpy Red right now.
+func (s *Service) IsRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	red, err := s.Store.ListChirpyRedUsers(ctx, []uuid.UUID{userID})
+	return len(red) > 0, err
+}
+
+// ForUsers returns the perks of each of userIDs, in one lookup.
+func (s *Service) ForUsers(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]Perks, error) {
+	perks := make(map[uuid.UUID]Perks, len(userIDs))
+	if len(userIDs) == 0 {
+		return perks, nil
+	}
+	red, err := s.Store.ListChirpyRedUsers(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		perks[id] = s.Free
+	}
+	for _, id := range red {
+		perks[id] = s.Red
+	}
+	return perks, nil
+}
@@ -0,0 +1,70 @@
+package entitlements
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type redUsers []uuid.UUID
+
+func (r redUsers) ListChirpyRedUsers(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var out []uuid.UUID
+	for _, id := range userIDs {
+		if slices.Contains(r, id) {
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func TestForUsers(t *testing.T) {
+	red, free := uuid.New(), uuid.New()
+	s := NewService(redUsers{red})
+
+	perks, err := s.ForUsers(context.Background(), []uuid.UUID{red, free})
+	if err != nil {
+		t.Fatalf("ForUsers() error %v", err)
+	}
+	if perks[red] != Red || perks[free] != Free {
+		t.Errorf("ForUsers() = %+v, want Red for %v and Free for %v", perks, red, free)
+	}
+
+	got, err := s.For(context.Background(), free)
+	if err != nil || got != Free {
+		t.Errorf("For(free user) = %+v, %v, want Free", got, err)
+	}
+
+	if isRed, err := s.IsRed(context.Background(), red); err != nil || !isRed {
+		t.Errorf("IsRed(red user) = %v, %v, want true", isRed, err)
+	}
+	if isRed, err := s.IsRed(context.Background(), free); err != nil || isRed {
+		t.Errorf("IsRed(free user) = %v, %v, want false", isRed, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rate := Rate{Chirps: 3, Per: time.Hour}
+
+	tests := []struct {
+		name   string
+		count  int64
+		oldest time.Time
+		want   time.Duration
+	}{
+		{name: "under the limit", count: 2, oldest: now.Add(-10 * time.Minute), want: 0},
+		{name: "at the limit", count: 3, oldest: now.Add(-10 * time.Minute), want: 50 * time.Minute},
+		{name: "oldest about to leave the window", count: 3, oldest: now.Add(-time.Hour), want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rate.RetryAfter(tt.count, tt.oldest, now); got != tt.want {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package lockout slows down password guessing by making clients wait
+// longer and longer after each failed login.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Policy says how quickly failures lock a key out.
+//
+// The first Free failures cost nothing. After that each failure locks the
+// key for Base, doubling every time up to Max, counted from the failure.
+// Failures are forgotten once there has been none for ForgetAfter.
+type Policy struct {
+	Free        int
+	Base        time.Duration
+	Max         time.Duration
+	ForgetAfter time.Duration
+}
+
+// DefaultAccountPolicy allows a few typos, then locks the account for up to
+// 15 minutes at a time.
+var DefaultAccountPolicy = Policy{
+	Free:        5,
+	Base:        time.Second,
+	Max:         15 * time.Minute,
+	ForgetAfter: time.Hour,
+}
+
+// DefaultIPPolicy is looser than DefaultAccountPolicy, since several people
+// can share an address, but still stops one client trying many accounts.
+var DefaultIPPolicy = Policy{
+	Free:        20,
+	Base:        time.Second,
+	Max:         15 * time.Minute,
+	ForgetAfter: time.Hour,
+}
+
+// lockedFor returns how long after its last failure s stays locked.
+func (p Policy) lockedFor(s State) time.Duration {
+	over := s.Failures - p.Free
+	if over <= 0 {
+		return 0
+	}
+	delay := p.Base
+	for range over - 1 {
+		if delay >= p.Max {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, p.Max)
+}
+
+// retryAfter returns how long from now until s may try again, or 0.
+func (p Policy) retryAfter(s State, now time.Time) time.Duration {
+	if s.Failures == 0 || now.Sub(s.LastFailure) >= p.ForgetAfter {
+		return 0
+	}
+	return max(s.LastFailure.Add(p.lockedFor(s)).Sub(now), 0)
+}
+
+// State is what a Store remembers about one key.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps failure counts. Use MemoryStore for a single instance and
+// DBStore when several instances share the load.
+type Store interface {
+	// Get returns the key's state, or the zero State if it has none.
+	Get(ctx context.Context, key string) (State, error)
+	// Swap sets the key's state to next if it's still prev, and reports
+	// whether it was. A zero prev means the key has no state yet.
+	Swap(ctx context.Context, key string, prev, next State) (bool, error)
+	// Release takes one failure back off the key's count.
+	Release(ctx context.Context, key string) error
+	// Reset forgets the key's failures.
+	Reset(ctx context.Context, key string) error
+	// Prune forgets every key whose last failure was before before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Limiter tracks failed logins both per account and per client IP.
+type Limiter struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+
+	now func() time.Time // for tests
+}
+
+// NewLimiter returns a Limiter using the default policies.
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{Store: store, Account: DefaultAccountPolicy, IP: DefaultIPPolicy}
+}
+
+// maxSwapTries bounds how often reserve retries when other attempts keep
+// changing a key under it.
+const maxSwapTries = 10
+
+// Attempt reserves a login attempt on account from ip, or returns how long
+// the caller has to wait first.
+//
+// A reserved attempt counts as a failure straight away, so guesses sent in
+// parallel can't all get in before any of them is recorded. Once the
+// password turns out to be right, call Pass or Succeed to take it back.
+func (l *Limiter) Attempt(ctx context.Context, account, ip string) (time.Duration, error) {
+	now := l.clock()
+	wait, err := l.reserve(ctx, accountKey(account), l.Account, now)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	wait, err = l.reserve(ctx, ipKey(ip), l.IP, now)
+	if err != nil || wait > 0 {
+		// the attempt isn't going ahead, so it mustn't count against the account
+		return wait, errors.Join(err, l.Store.Release(ctx, accountKey(account)))
+	}
+	return 0, nil
+}
+
+// reserve counts a failure against key unless p has it locked, in which
+// case it returns how long is left.
+func (l *Limiter) reserve(ctx context.Context, key string, p Policy, now time.Time) (time.Duration, error) {
+	for range maxSwapTries {
+		prev, err := l.Store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := p.retryAfter(prev, now); wait > 0 {
+			return wait, nil
+		}
+
+		next := State{Failures: prev.Failures + 1, LastFailure: now}
+		if now.Sub(prev.LastFailure) >= p.ForgetAfter {
+			next.Failures = 1
+		}
+		swapped, err := l.Store.Swap(ctx, key, prev, next)
+		if err != nil {
+			return 0, err
+		}
+		if swapped {
+			return 0, nil
+		}
+	}
+	// that many attempts at once on one key is reason enough to slow down
+	return p.Base, nil
+}
+
+// Pass takes back an attempt whose password was right but that isn't
+// finished yet, e.g. because a second factor is still to come. The
+// account's earlier failures stay until Succeed.
+func (l *Limiter) Pass(ctx context.Context, account, ip string) error {
+	return errors.Join(
+		l.Store.Release(ctx, accountKey(account)),
+		l.Store.Release(ctx, ipKey(ip)),
+	)
+}
+
+// Succeed clears the account's failures after a successful login, and
+// takes back the attempt against the IP. The IP's earlier failures are
+// kept, or logging in to one account of your own would reset the count for
+// guessing at everyone else's.
+func (l *Limiter) Succeed(ctx context.Context, account, ip string) error {
+	return errors.Join(
+		l.Store.Reset(ctx, accountKey(account)),
+		l.Store.Release(ctx, ipKey(ip)),
+	)
+}
+
+// Prune drops state that no policy remembers anymore. Call it now and then
+// so the store doesn't keep every address that ever mistyped a password.
+func (l *Limiter) Prune(ctx context.Context) error {
+	return l.Store.Prune(ctx, l.clock().Add(-max(l.Account.ForgetAfter, l.IP.ForgetAfter)))
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// accountKey ignores case and surrounding space, so "Bob@x.com " counts
+// against the same account as "bob@x.com".
+func accountKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,119 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPolicyLockedFor(t *testing.T) {
+	p := Policy{Free: 3, Base: time.Second, Max: 10 * time.Second, ForgetAfter: time.Hour}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 7, want: 8 * time.Second},
+		{failures: 8, want: 10 * time.Second},
+		{failures: 1000, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.lockedFor(State{Failures: tt.failures}); got != tt.want {
+			t.Errorf("lockedFor(%d failures) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(&MemoryStore{})
+	l.Account = Policy{Free: 1, Base: time.Minute, Max: time.Hour, ForgetAfter: time.Hour}
+	l.IP = Policy{Free: 3, Base: time.Minute, Max: time.Hour, ForgetAfter: time.Hour}
+	l.now = func() time.Time { return now }
+
+	attempt := func(account, ip string, want time.Duration) {
+		t.Helper()
+		got, err := l.Attempt(ctx, account, ip)
+		if err != nil {
+			t.Fatalf("Attempt() error %v", err)
+		}
+		if got != want {
+			t.Errorf("Attempt(%q, %q) = %v, want %v", account, ip, got, want)
+		}
+	}
+
+	// the first failure is free, the second locks the account
+	attempt("bob@example.com", "10.0.0.1", 0)
+	attempt("Bob@Example.com", "10.0.0.1", 0)
+	attempt("bob@example.com", "10.0.0.2", time.Minute)
+	attempt("alice@example.com", "10.0.0.1", 0)
+
+	// the lock runs out
+	now = now.Add(time.Minute)
+	attempt("bob@example.com", "10.0.0.1", 0)
+
+	// four failures lock the IP, and a locked IP doesn't count against the account
+	attempt("carol@example.com", "10.0.0.1", time.Minute)
+	attempt("carol@example.com", "10.0.0.2", 0)
+
+	// a right password with a second factor to come takes its attempt back
+	if err := l.Pass(ctx, "carol@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("Pass() error %v", err)
+	}
+	attempt("carol@example.com", "10.0.0.2", 0)
+
+	// logging in clears the account, but only this attempt on the IP
+	if err := l.Succeed(ctx, "carol@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("Succeed() error %v", err)
+	}
+	attempt("carol@example.com", "10.0.0.3", 0)
+	attempt("dave@example.com", "10.0.0.1", time.Minute)
+
+	// old failures are forgotten and pruned
+	now = now.Add(2 * time.Hour)
+	attempt("bob@example.com", "10.0.0.1", 0)
+	if err := l.Prune(ctx); err != nil {
+		t.Fatalf("Prune() error %v", err)
+	}
+	if n := len(l.Store.(*MemoryStore).states); n != 2 {
+		t.Errorf("after Prune() %d keys left, want 2", n)
+	}
+}
+
+func TestLimiterParallelGuesses(t *testing.T) {
+	l := NewLimiter(&MemoryStore{})
+	l.Account = Policy{Free: 3, Base: time.Minute, Max: time.Hour, ForgetAfter: time.Hour}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := l.Attempt(context.Background(), "bob@example.com", "10.0.0.1")
+			if err != nil {
+				t.Errorf("Attempt() error %v", err)
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// three free failures, then the fourth locks
+	if allowed != 4 {
+		t.Errorf("%d parallel guesses allowed, want 4", allowed)
+	}
+}
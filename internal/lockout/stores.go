@@ -0,0 +1,119 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+// MemoryStore keeps failure counts in the process. Each instance counts
+// separately, so use DBStore if there's more than one.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[key], nil
+}
+
+func (m *MemoryStore) Swap(ctx context.Context, key string, prev, next State) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.states == nil {
+		m.states = make(map[string]State)
+	}
+	cur := m.states[key]
+	if cur.Failures != prev.Failures || !cur.LastFailure.Equal(prev.LastFailure) {
+		return false, nil
+	}
+	m.states[key] = next
+	return true, nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.states[key]; ok && s.Failures > 0 {
+		s.Failures--
+		m.states[key] = s
+	}
+	return nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, key)
+	return nil
+}
+
+func (m *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, s := range m.states {
+		if s.LastFailure.Before(before) {
+			delete(m.states, key)
+		}
+	}
+	return nil
+}
+
+// DBStore keeps failure counts in the login_throttles table, shared by every
+// instance.
+type DBStore struct {
+	Db *database.Queries
+}
+
+func (s DBStore) Get(ctx context.Context, key string) (State, error) {
+	row, err := s.Db.GetLoginThrottle(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return State{Failures: int(row.Failures), LastFailure: row.LastFailureAt}, nil
+}
+
+func (s DBStore) Swap(ctx context.Context, key string, prev, next State) (bool, error) {
+	var (
+		n   int64
+		err error
+	)
+	if prev == (State{}) {
+		n, err = s.Db.InsertLoginThrottle(ctx, database.InsertLoginThrottleParams{
+			Key:           key,
+			Failures:      int32(next.Failures),
+			LastFailureAt: next.LastFailure,
+		})
+	} else {
+		n, err = s.Db.SwapLoginThrottle(ctx, database.SwapLoginThrottleParams{
+			Failures:          int32(next.Failures),
+			LastFailureAt:     next.LastFailure,
+			Key:               key,
+			PrevFailures:      int32(prev.Failures),
+			PrevLastFailureAt: prev.LastFailure,
+		})
+	}
+	return n == 1, err
+}
+
+func (s DBStore) Release(ctx context.Context, key string) error {
+	return s.Db.ReleaseLoginThrottle(ctx, key)
+}
+
+func (s DBStore) Reset(ctx context.Context, key string) error {
+	return s.Db.ResetLoginThrottle(ctx, key)
+}
+
+func (s DBStore) Prune(ctx context.Context, before time.Time) error {
+	return s.Db.PruneLoginThrottles(ctx, before)
+}
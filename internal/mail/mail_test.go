@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := FileMailer{Dir: dir, From: "chirpy@example.com"}
+
+	err := m.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("FileMailer wrote %d files, want 1", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"To: alice@example.com\r\n", "Subject: Reset your password\r\n", "\r\n\r\nline one\r\nline two"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message is missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m := FileMailer{Dir: t.TempDir(), From: "chirpy@example.com"}
+	err := m.Send(context.Background(), Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "hi",
+	})
+	if err == nil {
+		t.Error("Send() accepted a line break in a header")
+	}
+}
@@ -0,0 +1,184 @@
+// Package moderation cleans user-submitted text before it's stored.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mask replaces every blocked word, whatever its length.
+const Mask = "****"
+
+// Filter rewrites text so it's safe to publish.
+type Filter interface {
+	Clean(text string) string
+}
+
+// Store is where a WordList keeps its words between restarts.
+type Store interface {
+	Load(ctx context.Context) ([]string, error)
+	Add(ctx context.Context, word string) error
+	Remove(ctx context.Context, word string) error
+}
+
+// WordList is a Filter that masks whole words found in its Store.
+//
+// A "word" is a run of letters and digits, so punctuation around it
+// ("kerfuffle!", "(fornax)") doesn't hide it. Matching ignores case in any
+// script ("KerFuffle", "ÇÖREK") and how the word is encoded: decomposed
+// accents, fullwidth letters and spellings like "ß" for "ss" all match.
+// It's safe for concurrent use; Reload swaps the list in place.
+type WordList struct {
+	store Store
+
+	mu    sync.RWMutex
+	words map[string]struct{}
+}
+
+// NewWordList creates a WordList and loads its words from store.
+func NewWordList(ctx context.Context, store Store) (*WordList, error) {
+	wl := &WordList{store: store}
+	if err := wl.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return wl, nil
+}
+
+// Reload re-reads the words from the store, replacing the current list.
+func (wl *WordList) Reload(ctx context.Context) error {
+	loaded, err := wl.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	words := make(map[string]struct{}, len(loaded))
+	for _, w := range loaded {
+		word, err := NormalizeWord(w)
+		if err != nil {
+			continue
+		}
+		words[word] = struct{}{}
+	}
+
+	wl.mu.Lock()
+	wl.words = words
+	wl.mu.Unlock()
+	return nil
+}
+
+// Words returns the blocked words in alphabetical order.
+func (wl *WordList) Words() []string {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	words := make([]string, 0, len(wl.words))
+	for w := range wl.words {
+		words = append(words, w)
+	}
+	slices.Sort(words)
+	return words
+}
+
+// Add blocks a new word, persisting it to the store first.
+func (wl *WordList) Add(ctx context.Context, word string) error {
+	word, err := NormalizeWord(word)
+	if err != nil {
+		return err
+	}
+	if err := wl.store.Add(ctx, word); err != nil {
+		return err
+	}
+
+	wl.mu.Lock()
+	wl.words[word] = struct{}{}
+	wl.mu.Unlock()
+	return nil
+}
+
+// Remove unblocks a word, removing it from the store first.
+func (wl *WordList) Remove(ctx context.Context, word string) error {
+	word, err := NormalizeWord(word)
+	if err != nil {
+		return err
+	}
+	if err := wl.store.Remove(ctx, word); err != nil {
+		return err
+	}
+
+	wl.mu.Lock()
+	delete(wl.words, word)
+	wl.mu.Unlock()
+	return nil
+}
+
+// Clean masks every blocked word in text and leaves everything else,
+// including spacing and punctuation, exactly as it was.
+func (wl *WordList) Clean(text string) string {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	var b strings.Builder
+	b.Grow(len(text))
+
+	start := -1
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			if start >= 0 {
+				b.WriteString(wl.maskWord(text[start:i]))
+				start = -1
+			}
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	if start >= 0 {
+		b.WriteString(wl.maskWord(text[start:]))
+	}
+	return b.String()
+}
+
+func (wl *WordList) maskWord(word string) string {
+	if _, blocked := wl.words[fold(word)]; blocked {
+		return Mask
+	}
+	return word
+}
+
+// NormalizeWord case-folds word and checks it's something Clean can match,
+// i.e. a single run of letters and digits.
+func NormalizeWord(word string) (string, error) {
+	word = fold(strings.TrimSpace(word))
+	if word == "" {
+		return "", errors.New("word cannot be empty")
+	}
+	for _, r := range word {
+		if !isWordRune(r) {
+			return "", errors.New("word can only contain letters and digits")
+		}
+	}
+	return word, nil
+}
+
+// fold maps the ways of writing a word to one form: NFKC, so composed and
+// decomposed accents and compatibility forms like fullwidth letters agree,
+// then full case folding, so "ß" and "ss", or "ſ" and "s", do too.
+func fold(word string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(word)))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,132 @@
+package moderation
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+type memStore struct {
+	words []string
+}
+
+func (m *memStore) Load(ctx context.Context) ([]string, error) { return m.words, nil }
+func (m *memStore) Add(ctx context.Context, word string) error {
+	m.words = append(m.words, word)
+	return nil
+}
+func (m *memStore) Remove(ctx context.Context, word string) error { return nil }
+
+func TestWordListClean(t *testing.T) {
+	wl, err := NewWordList(context.Background(), &memStore{words: []string{"kerfuffle", "Sharbert", "fornax", "çörek", "café", "straße"}})
+	if err != nil {
+		t.Fatalf("NewWordList() error %v", err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "no blocked words",
+			text: "I had something interesting for breakfast",
+			want: "I had something interesting for breakfast",
+		},
+		{
+			name: "whole word",
+			text: "I hear Mastodon is better than Chirpy. sharbert I need to migrate",
+			want: "I hear Mastodon is better than Chirpy. **** I need to migrate",
+		},
+		{
+			name: "trailing punctuation",
+			text: "What a kerfuffle!",
+			want: "What a ****!",
+		},
+		{
+			name: "mixed case",
+			text: "KerFuffle and FORNAX",
+			want: "**** and ****",
+		},
+		{
+			name: "wrapped in punctuation",
+			text: "(fornax), \"sharbert\"",
+			want: "(****), \"****\"",
+		},
+		{
+			name: "unicode word",
+			text: "Çörek? no thanks",
+			want: "****? no thanks",
+		},
+		{
+			name: "decomposed accent",
+			text: "meet me at the cafe\u0301",
+			want: "meet me at the ****",
+		},
+		{
+			name: "fullwidth letters",
+			text: "ｆｏｒｎａｘ!",
+			want: "****!",
+		},
+		{
+			name: "case folding beyond lower-casing",
+			text: "STRASSE and ſharbert",
+			want: "**** and ****",
+		},
+		{
+			name: "substring is left alone",
+			text: "kerfuffles and fornaxes",
+			want: "kerfuffles and fornaxes",
+		},
+		{
+			name: "spacing is preserved",
+			text: "  fornax\tand   friends ",
+			want: "  ****\tand   friends ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wl.Clean(tt.text); got != tt.want {
+				t.Errorf("Clean() expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWordListEdit(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "words.txt")}
+	wl, err := NewWordList(context.Background(), store)
+	if err != nil {
+		t.Fatalf("NewWordList() error %v", err)
+	}
+
+	if err := wl.Add(context.Background(), " Fornax "); err != nil {
+		t.Fatalf("Add() error %v", err)
+	}
+	if err := wl.Add(context.Background(), "two words"); err == nil {
+		t.Errorf("Add() expected an error for a word with a space")
+	}
+	if got := wl.Clean("fornax"); got != Mask {
+		t.Errorf("Clean() expected %q, got %q", Mask, got)
+	}
+
+	// a fresh list reading the same file sees the edit
+	reloaded, err := NewWordList(context.Background(), store)
+	if err != nil {
+		t.Fatalf("NewWordList() error %v", err)
+	}
+	if words := reloaded.Words(); len(words) != 1 || words[0] != "fornax" {
+		t.Errorf("Words() expected [fornax], got %v", words)
+	}
+
+	if err := wl.Remove(context.Background(), "FORNAX"); err != nil {
+		t.Fatalf("Remove() error %v", err)
+	}
+	if err := reloaded.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error %v", err)
+	}
+	if words := reloaded.Words(); len(words) != 0 {
+		t.Errorf("Words() expected no words after Remove, got %v", words)
+	}
+}
@@ -0,0 +1,115 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/grainme/Chirpy/internal/database"
+)
+
+// FileStore keeps words in a text file, one per line.
+// Blank lines and lines starting with '#' are ignored when loading,
+// but they're not preserved when the file is rewritten by Add or Remove.
+type FileStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (fs *FileStore) Load(ctx context.Context) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.read()
+}
+
+func (fs *FileStore) Add(ctx context.Context, word string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	words, err := fs.read()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(words, word) {
+		return nil
+	}
+	return fs.write(append(words, word))
+}
+
+func (fs *FileStore) Remove(ctx context.Context, word string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	words, err := fs.read()
+	if err != nil {
+		return err
+	}
+	return fs.write(slices.DeleteFunc(words, func(w string) bool { return w == word }))
+}
+
+func (fs *FileStore) read() ([]string, error) {
+	f, err := os.Open(fs.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.ToLower(line))
+	}
+	return words, scanner.Err()
+}
+
+// write replaces the file atomically so a concurrent Load never sees half of it.
+func (fs *FileStore) write(words []string) error {
+	slices.Sort(words)
+
+	tmp, err := os.CreateTemp(filepath.Dir(fs.Path), ".words-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, w := range words {
+		if _, err := tmp.WriteString(w + "\n"); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.Path)
+}
+
+// DBStore keeps words in the profanity_words table.
+type DBStore struct {
+	Db *database.Queries
+}
+
+func (s DBStore) Load(ctx context.Context) ([]string, error) {
+	return s.Db.ListProfanityWords(ctx)
+}
+
+func (s DBStore) Add(ctx context.Context, word string) error {
+	return s.Db.AddProfanityWord(ctx, word)
+}
+
+func (s DBStore) Remove(ctx context.Context, word string) error {
+	return s.Db.DeleteProfanityWord(ctx, word)
+}
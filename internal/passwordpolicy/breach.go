@@ -0,0 +1,55 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachChecker reports whether a password is known to have leaked.
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// PrefixDir checks passwords against a local copy of a breached password
+// corpus split the way the Have I Been Pwned range API splits it: one file
+// per first five hex digits of the uppercase SHA-1, named like
+// "5BAA6.txt", each line being the other 35 digits and a count:
+//
+//	1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493
+//
+// Nothing leaves the machine, and only the one small file for the
+// password's prefix is read.
+type PrefixDir struct {
+	Dir string
+}
+
+func (d PrefixDir) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(d.Dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		// no file means no leaked password has that prefix
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), ":")
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,113 @@
+// Package passwordpolicy decides whether a password is good enough to
+// accept: long enough, hard enough to guess, unrelated to the account's
+// email and not in a list of breached passwords.
+package passwordpolicy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation codes, stable for clients to switch on.
+const (
+	CodeRequired      = "required"
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeContainsEmail = "contains_email"
+	CodeTooWeak       = "too_weak"
+	CodeBreached      = "breached"
+)
+
+// Violation is one reason a password was rejected.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Policy is the set of rules a new password has to pass.
+type Policy struct {
+	MinLength int // in characters
+	MaxLength int // in characters; also bounds the work Strength does
+
+	// MinStrength is the least Strength, in bits, a password may have.
+	// 28 bits is about 2^28 guesses, what zxcvbn calls "safely
+	// unguessable" against an online attack.
+	MinStrength float64
+
+	// Breached, if set, is consulted last, and only for passwords that
+	// passed everything else.
+	Breached BreachChecker
+}
+
+// DefaultPolicy is used unless the server is configured otherwise.
+var DefaultPolicy = Policy{
+	MinLength:   8,
+	MaxLength:   128,
+	MinStrength: 28,
+}
+
+// Check returns every rule password breaks, or nil if it's acceptable for
+// the account with the given email. It only returns an error when the
+// breach check couldn't be done.
+func (p Policy) Check(ctx context.Context, password, email string) ([]Violation, error) {
+	length := utf8.RuneCountInString(password)
+	if length == 0 {
+		return []Violation{{Code: CodeRequired, Message: "Password cannot be empty"}}, nil
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return []Violation{{Code: CodeTooLong, Message: fmt.Sprintf("Password can be at most %d characters", p.MaxLength)}}, nil
+	}
+
+	var violations []Violation
+	if length < p.MinLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if containsEmail(password, email) {
+		violations = append(violations, Violation{
+			Code:    CodeContainsEmail,
+			Message: "Password cannot contain your email address",
+		})
+	}
+	if Strength(password) < p.MinStrength {
+		violations = append(violations, Violation{
+			Code:    CodeTooWeak,
+			Message: "Password is too easy to guess; avoid common words, names, dates and patterns like 'abc' or 'qwerty'",
+		})
+	}
+	if len(violations) > 0 || p.Breached == nil {
+		return violations, nil
+	}
+
+	breached, err := p.Breached.Breached(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+	if breached {
+		violations = append(violations, Violation{
+			Code:    CodeBreached,
+			Message: "Password has appeared in a data breach; choose a different one",
+		})
+	}
+	return violations, nil
+}
+
+// containsEmail reports whether password contains the email or its local
+// part, ignoring case. Very short local parts ("jo@...") are only caught
+// as the whole address, or every password with a "jo" in it would fail.
+func containsEmail(password, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	if strings.Contains(password, email) {
+		return true
+	}
+	local, _, _ := strings.Cut(email, "@")
+	return utf8.RuneCountInString(local) >= 3 && strings.Contains(password, local)
+}
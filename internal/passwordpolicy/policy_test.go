@@ -0,0 +1,102 @@
+package passwordpolicy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStrength(t *testing.T) {
+	weak := []string{
+		"password",
+		"P@ssw0rd",
+		"Password2024",
+		"qwertyuiop",
+		"abcdefghij",
+		"aaaaaaaaaaaa",
+		"abcabcabcabc",
+		"19900417",
+		"drowssap",
+		"iloveyou123",
+	}
+	for _, pw := range weak {
+		if s := Strength(pw); s >= DefaultPolicy.MinStrength {
+			t.Errorf("Strength(%q) = %.1f, want below %v", pw, s, DefaultPolicy.MinStrength)
+		}
+	}
+
+	strong := []string{
+		"Tr0ub4dor&3x",
+		"vQ8#mZ2!rk",
+		"correct horse battery staple",
+		"kumquat-lantern-bivouac",
+	}
+	for _, pw := range strong {
+		if s := Strength(pw); s < DefaultPolicy.MinStrength {
+			t.Errorf("Strength(%q) = %.1f, want at least %v", pw, s, DefaultPolicy.MinStrength)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "empty", password: "", want: []string{CodeRequired}},
+		{name: "short and weak", password: "abc", want: []string{CodeTooShort, CodeTooWeak}},
+		{name: "contains email local part", password: "walt.whitman-Grass!", email: "walt.whitman@example.com", want: []string{CodeContainsEmail}},
+		{name: "short local part only matched whole", password: "vQ8#jo-mZ2!rk", email: "jo@example.com"},
+		{name: "too long", password: string(make([]byte, 200)), want: []string{CodeTooLong}},
+		{name: "fine", password: "kumquat-lantern-bivouac", email: "walt@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := DefaultPolicy.Check(context.Background(), tt.password, tt.email)
+			if err != nil {
+				t.Fatalf("Check() error %v", err)
+			}
+			var codes []string
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			if !slices.Equal(codes, tt.want) {
+				t.Errorf("Check() = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixDir(t *testing.T) {
+	dir := t.TempDir()
+	// the SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	if err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(
+		"0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n"+
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultPolicy
+	policy.MinStrength = 0
+	policy.Breached = PrefixDir{Dir: dir}
+
+	violations, err := policy.Check(context.Background(), "password", "")
+	if err != nil {
+		t.Fatalf("Check() error %v", err)
+	}
+	if len(violations) != 1 || violations[0].Code != CodeBreached {
+		t.Errorf("Check(breached password) = %v, want one %q", violations, CodeBreached)
+	}
+
+	violations, err = policy.Check(context.Background(), "kumquat-lantern-bivouac", "")
+	if err != nil {
+		t.Fatalf("Check() error %v", err)
+	}
+	if len(violations) != 0 {
+		t.Errorf("Check(unbreached password) = %v, want none", violations)
+	}
+}
@@ -0,0 +1,302 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+//go:embed common.txt
+var commonList string
+
+// commonRanks maps each entry of common.txt to its 1-based rank.
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(strings.NewReader(commonList))
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if _, dup := ranks[word]; !dup {
+			ranks[word] = len(ranks) + 1
+		}
+	}
+	return ranks
+}()
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// leetVariants are the usual character swaps, as alternative readings so
+// that an ambiguous "1" is tried as both "i" and "l".
+var leetVariants = []map[rune]rune{
+	{'4': 'a', '@': 'a', '8': 'b', '3': 'e', '6': 'g', '9': 'g', '1': 'i', '!': 'i', '0': 'o', '5': 's', '$': 's', '7': 't', '2': 'z'},
+	{'4': 'a', '@': 'a', '8': 'b', '3': 'e', '6': 'g', '9': 'g', '1': 'l', '|': 'l', '0': 'o', '5': 's', '$': 's', '7': 't', '2': 'z'},
+}
+
+// Strength estimates, in bits (log2 of the number of guesses), how hard
+// password is to guess for an attacker who tries common passwords and
+// patterns before anything else.
+//
+// Like zxcvbn, it covers the password with the cheapest run of pieces,
+// each either a common word (l33t and capitals included), a keyboard run
+// ("qwerty"), a sequence ("abc", "9876"), a repeat ("aaaa", "abab"), a
+// year or date, or failing all that, random characters. So "P@ssw0rd2024"
+// scores about as low as "password" plus a year, not as 12 random
+// characters would.
+func Strength(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+	charset := cardinality(runes)
+
+	// best[i] is the cheapest cover of runes[:i]
+	best := make([]float64, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = math.Inf(1)
+		for start := range end {
+			bits := best[start] + pieceBits(runes[start:end], charset)
+			if start > 0 {
+				bits++ // each extra piece doubles the ways to combine them
+			}
+			best[end] = min(best[end], bits)
+		}
+	}
+	return best[len(runes)]
+}
+
+// pieceBits is the cheapest way to guess piece on its own.
+func pieceBits(piece []rune, charset float64) float64 {
+	bits := float64(len(piece)) * math.Log2(charset)
+	if len(piece) < 3 {
+		return bits
+	}
+	for _, f := range []func([]rune) (float64, bool){dictionaryBits, keyboardBits, sequenceBits, repeatBits, dateBits} {
+		if b, ok := f(piece); ok {
+			bits = min(bits, b)
+		}
+	}
+	return bits
+}
+
+func dictionaryBits(piece []rune) (float64, bool) {
+	lower := toLower(piece)
+	bits, found := math.Inf(1), false
+	try := func(word string, extra float64) {
+		if rank, ok := commonRanks[word]; ok {
+			bits = min(bits, math.Log2(float64(rank))+1+extra)
+			found = true
+		}
+	}
+
+	word := string(lower)
+	reversed := string(reverse(lower))
+	try(word, 0)
+	try(reversed, 1)
+	for _, variant := range leetVariants {
+		unleeted := make([]rune, len(lower))
+		swapped := 0
+		for i, r := range lower {
+			if s, ok := variant[r]; ok {
+				r = s
+				swapped++
+			}
+			unleeted[i] = r
+		}
+		if swapped > 0 {
+			try(string(unleeted), float64(swapped))
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return bits + caseBits(piece), true
+}
+
+// caseBits is what capitalisation adds to a word: nothing for "password",
+// one bit for "Password" or "PASSWORD", and more for "pAssWoRd".
+func caseBits(piece []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range piece {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 0
+	}
+	if lower == 0 || (upper == 1 && unicode.IsUpper(piece[0])) {
+		return 1
+	}
+	// any choice of up to min(upper, lower) letters to flip
+	var choices float64
+	for k := 1; k <= min(upper, lower); k++ {
+		choices += binomial(upper+lower, k)
+	}
+	return math.Log2(choices)
+}
+
+func keyboardBits(piece []rune) (float64, bool) {
+	word := string(toLower(piece))
+	for _, row := range keyboardRows {
+		if strings.Contains(row, word) {
+			return math.Log2(47) + math.Log2(float64(len(piece))), true
+		}
+		if strings.Contains(row, string(reverse([]rune(word)))) {
+			return math.Log2(47) + math.Log2(float64(len(piece))) + 1, true
+		}
+	}
+	return 0, false
+}
+
+// sequenceBits matches evenly spaced runs like "abcd", "1357" or "zyx".
+func sequenceBits(piece []rune) (float64, bool) {
+	delta := piece[1] - piece[0]
+	if delta == 0 || delta > 2 || delta < -2 {
+		return 0, false
+	}
+	for i := 2; i < len(piece); i++ {
+		if piece[i]-piece[i-1] != delta {
+			return 0, false
+		}
+	}
+
+	var start float64
+	switch first := piece[0]; {
+	case strings.ContainsRune("aAzZ019", first):
+		start = 1
+	case unicode.IsDigit(first):
+		start = math.Log2(10)
+	case unicode.IsLower(first):
+		start = math.Log2(26)
+	default:
+		start = math.Log2(26) + 1
+	}
+	bits := start + math.Log2(float64(len(piece)))
+	if delta < 0 {
+		bits++
+	}
+	return bits, true
+}
+
+// repeatBits matches a chunk repeated: "aaaa", "abcabc", "lol lol ".
+func repeatBits(piece []rune) (float64, bool) {
+	for size := 1; size <= len(piece)/2; size++ {
+		if len(piece)%size != 0 {
+			continue
+		}
+		chunk := piece[:size]
+		repeats := true
+		for i := size; i < len(piece); i++ {
+			if piece[i] != chunk[i%size] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return Strength(string(chunk)) + math.Log2(float64(len(piece)/size)), true
+		}
+	}
+	return 0, false
+}
+
+// dateBits matches years from 1900 to 2049 and six or eight digit dates
+// with the year first or last ("19900417", "170490").
+func dateBits(piece []rune) (float64, bool) {
+	for _, r := range piece {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	digits := string(piece)
+	switch len(digits) {
+	case 4:
+		if isYear(digits) {
+			return math.Log2(150), true
+		}
+	case 6:
+		if isDayMonth(digits[:4]) || isDayMonth(digits[2:]) {
+			return math.Log2(366 * 100), true
+		}
+	case 8:
+		if (isYear(digits[:4]) && isDayMonth(digits[4:])) || (isYear(digits[4:]) && isDayMonth(digits[:4])) {
+			return math.Log2(366 * 150), true
+		}
+	}
+	return 0, false
+}
+
+func isYear(s string) bool {
+	return (s >= "1900" && s <= "1999") || (s >= "2000" && s <= "2049")
+}
+
+// isDayMonth accepts "DDMM" or "MMDD".
+func isDayMonth(s string) bool {
+	a, b := (s[0]-'0')*10+s[1]-'0', (s[2]-'0')*10+s[3]-'0'
+	valid := func(day, month byte) bool { return day >= 1 && day <= 31 && month >= 1 && month <= 12 }
+	return valid(a, b) || valid(b, a)
+}
+
+// cardinality is the size of the alphabet password seems to be drawn from.
+func cardinality(runes []rune) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	var n float64
+	for _, c := range []struct {
+		used bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.used {
+			n += c.size
+		}
+	}
+	return n
+}
+
+func toLower(runes []rune) []rune {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
+
+func reverse(runes []rune) []rune {
+	reversed := make([]rune, len(runes))
+	for i, r := range runes {
+		reversed[len(runes)-1-i] = r
+	}
+	return reversed
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
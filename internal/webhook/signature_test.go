@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	v, err := NewVerifier("current-secret", "previous-secret")
+	if err != nil {
+		t.Fatalf("NewVerifier() error %v", err)
+	}
+	v.now = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{name: "current secret", header: Sign("current-secret", now, body), body: body},
+		{name: "previous secret", header: Sign("previous-secret", now.Add(-time.Minute), body), body: body},
+		{
+			name:   "one of several signatures",
+			header: Sign("current-secret", now, body) + ",v1=" + "00ff",
+			body:   body,
+		},
+		{name: "unknown secret", header: Sign("other-secret", now, body), body: body, wantErr: ErrBadSignature},
+		{name: "body changed", header: Sign("current-secret", now, body), body: []byte(`{}`), wantErr: ErrBadSignature},
+		{name: "too old", header: Sign("current-secret", now.Add(-6*time.Minute), body), body: body, wantErr: ErrStaleTimestamp},
+		{name: "from the future", header: Sign("current-secret", now.Add(6*time.Minute), body), body: body, wantErr: ErrStaleTimestamp},
+		{name: "missing", header: "", body: body, wantErr: ErrNoSignature},
+		{name: "no timestamp", header: "v1=abcd", body: body, wantErr: ErrBadHeader},
+		{name: "not hex", header: "t=1735732800,v1=zz", body: body, wantErr: ErrBadHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(tt.header, tt.body)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewVerifierNeedsASecret(t *testing.T) {
+	if _, err := NewVerifier("", ""); err == nil {
+		t.Error("NewVerifier() with no secrets: expected an error")
+	}
+}
@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/grainme/Chirpy/handlers"
+	"github.com/grainme/Chirpy/internal/auth"
+	"github.com/grainme/Chirpy/internal/billing"
 	"github.com/grainme/Chirpy/internal/database"
+	"github.com/grainme/Chirpy/internal/entitlements"
+	"github.com/grainme/Chirpy/internal/lockout"
+	"github.com/grainme/Chirpy/internal/mail"
+	"github.com/grainme/Chirpy/internal/moderation"
+	"github.com/grainme/Chirpy/internal/passwordpolicy"
+	"github.com/grainme/Chirpy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -28,14 +43,43 @@ func main() {
 		log.Fatalf("couldn't read .env files: %s", err)
 	}
 
-	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		log.Fatal("POLKA_KEY must be set")
+	billingProviders, err := billingProvidersFromEnv()
+	if err != nil {
+		log.Fatalf("invalid billing settings: %s", err)
+	}
+
+	// access tokens are signed with the keys in JWT_KEYS_DIR, falling back
+	// to the shared JWT_SecretToken (HS256) when it isn't set
+	var jwtKeys *auth.Keyring
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); keysDir != "" {
+		jwtKeys, err = auth.LoadKeyring(keysDir, os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			log.Fatalf("failed loading JWT keys: %s", err)
+		}
+	} else {
+		secretToken := os.Getenv("JWT_SecretToken")
+		if secretToken == "" {
+			log.Fatal("JWT_KEYS_DIR or JWT_SecretToken must be set")
+		}
+		jwtKeys = auth.NewHMACKeyring(secretToken)
+	}
+	jwtKeys.Validation, err = jwtValidationFromEnv()
+	if err != nil {
+		log.Fatalf("invalid JWT settings: %s", err)
+	}
+
+	passwordParams, err := passwordParamsFromEnv()
+	if err != nil {
+		log.Fatalf("invalid Argon2 settings: %s", err)
+	}
+	passwords, err := auth.NewPasswordHasher(passwordParams)
+	if err != nil {
+		log.Fatalf("invalid Argon2 settings: %s", err)
 	}
 
-	secretToken := os.Getenv("JWT_SecretToken")
-	if secretToken == "" {
-		log.Fatal("JWT_SecretToken must be set")
+	passwordPolicy, err := passwordPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("invalid password policy: %s", err)
 	}
 
 	dbURL := os.Getenv("DB_URL")
@@ -47,6 +91,9 @@ func main() {
 		log.Fatal("PLATFORM must be set")
 	}
 
+	// optional: admin endpoints are disabled without it
+	adminKey := os.Getenv("ADMIN_KEY")
+
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("failed opening database: %s", err)
@@ -54,29 +101,123 @@ func main() {
 	defer db.Close()
 	dbQueries := database.New(db)
 
+	// the profanity list lives in the database unless PROFANITY_FILE points elsewhere
+	var wordStore moderation.Store = moderation.DBStore{Db: dbQueries}
+	if profanityFile := os.Getenv("PROFANITY_FILE"); profanityFile != "" {
+		wordStore = &moderation.FileStore{Path: profanityFile}
+	}
+	wordList, err := moderation.NewWordList(context.Background(), wordStore)
+	if err != nil {
+		log.Fatalf("failed loading profanity words: %s", err)
+	}
+
+	// optional: where users reach Chirpy, for links in emails
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
+	// optional: set to true to stop unverified users posting chirps
+	requireVerifiedEmail := false
+	if v := os.Getenv("REQUIRE_VERIFIED_EMAIL"); v != "" {
+		requireVerifiedEmail, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("invalid REQUIRE_VERIFIED_EMAIL: %s", err)
+		}
+	}
+
+	// optional: "memory" counts failed logins per instance, "postgres" (the
+	// default) shares the counts between instances
+	var lockoutStore lockout.Store
+	switch backend := os.Getenv("LOGIN_LIMITER"); backend {
+	case "", "postgres":
+		lockoutStore = lockout.DBStore{Db: dbQueries}
+	case "memory":
+		lockoutStore = &lockout.MemoryStore{}
+	default:
+		log.Fatalf("invalid LOGIN_LIMITER %q: must be memory or postgres", backend)
+	}
+
+	// optional: how long users stay Red after a paid period ends unrenewed
+	gracePeriod := handlers.DefaultSubscriptionGracePeriod
+	if raw := os.Getenv("SUBSCRIPTION_GRACE_PERIOD"); raw != "" {
+		gracePeriod, err = time.ParseDuration(raw)
+		if err != nil || gracePeriod < 0 {
+			log.Fatalf("SUBSCRIPTION_GRACE_PERIOD must be a non-negative duration, got %q", raw)
+		}
+	}
+
 	apiCfg := handlers.ApiConfig{
 		FileServerHits: atomic.Int32{},
 		Db:             dbQueries,
+		DbConn:         db,
 		Platform:       platform,
-		JWTSecretToken: secretToken,
-		PolkaKey:       polkaKey,
+		JWTKeys:        jwtKeys,
+		Passwords:      passwords,
+		PasswordPolicy: passwordPolicy,
+		AdminKey:       adminKey,
+		ChirpFilter:    wordList,
+		WordList:       wordList,
+		Mailer:         mailerFromEnv(),
+		BaseURL:        baseURL,
+		LoginLimiter:   lockout.NewLimiter(lockoutStore),
+		Entitlements:   entitlements.NewService(dbQueries),
+
+		RequireVerifiedEmail:    requireVerifiedEmail,
+		SubscriptionGracePeriod: gracePeriod,
+		BillingProviders:        billingProviders,
 	}
 
+	go apiCfg.PruneLoginLimitsEvery(10 * time.Minute)
+	go apiCfg.ExpireSubscriptionsEvery(5 * time.Minute)
+
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.MiddlewareMetricsInc(handler()))
 	mux.HandleFunc("GET /admin/metrics", apiCfg.HandlerMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.HandlerReset)
+	mux.HandleFunc("GET /admin/moderation/words", apiCfg.HandlerListProfanityWords)
+	mux.HandleFunc("POST /admin/moderation/words", apiCfg.HandlerAddProfanityWord)
+	mux.HandleFunc("DELETE /admin/moderation/words/{word}", apiCfg.HandlerDeleteProfanityWord)
+	mux.HandleFunc("POST /admin/moderation/reload", apiCfg.HandlerReloadProfanityWords)
+	mux.HandleFunc("GET /admin/webhooks/events", apiCfg.HandlerListWebhookEvents)
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/reprocess", apiCfg.HandlerReprocessWebhookEvent)
 	mux.HandleFunc("GET /api/healthz", handlers.HandlerReadiness)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.HandlerJWKS)
 	mux.HandleFunc("POST /api/users", apiCfg.HandlerInsertUser)
 	mux.HandleFunc("PUT /api/users", apiCfg.HandlerUpdateUser)
+	mux.HandleFunc("GET /api/users/{handleOrID}", apiCfg.HandlerGetUserProfile)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.HandlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.HandlerUnfollowUser)
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.HandlerGetMyMentions)
+	mux.HandleFunc("GET /api/timeline", apiCfg.HandlerGetTimeline)
+	mux.HandleFunc("GET /api/hashtags/trending", apiCfg.HandlerTrendingHashtags)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.HandlerGetHashtagChirps)
 	mux.HandleFunc("POST /api/chirps", apiCfg.HandlerValidateAndSaveChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.HandlerGetAllChirps)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.HandlerSearchChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.HandlerGetChirpById)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.HandlerUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.HandlerDeleteChirpById)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.HandlerGetChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.HandlerGetChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.HandlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.HandlerUnlikeChirp)
 	mux.HandleFunc("POST /api/login", apiCfg.HandlerUserLogin)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.HandlerLogin2FA)
+	mux.HandleFunc("POST /api/email/verify", apiCfg.HandlerVerifyEmail)
+	mux.HandleFunc("POST /api/email/verification", apiCfg.HandlerResendVerification)
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.HandlerForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.HandlerResetPassword)
+	mux.HandleFunc("POST /api/2fa/totp/setup", apiCfg.HandlerSetupTOTP)
+	mux.HandleFunc("POST /api/2fa/totp/confirm", apiCfg.HandlerConfirmTOTP)
+	mux.HandleFunc("POST /api/2fa/totp/disable", apiCfg.HandlerDisableTOTP)
 	mux.HandleFunc("POST /api/refresh", apiCfg.HandlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.HandlerRevoke)
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.HandlerUpgradeUser)
+	mux.HandleFunc("GET /api/sessions", apiCfg.HandlerListSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.HandlerRevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-others", apiCfg.HandlerRevokeOtherSessions)
+	mux.HandleFunc("POST /api/billing/{provider}/webhooks", apiCfg.HandlerBillingWebhook)
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.HandlerPolkaWebhook)
 
 	server := &http.Server{
 		Handler: mux,
@@ -86,3 +227,171 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// jwtValidationFromEnv reads the optional JWT_ISSUER, JWT_AUDIENCE,
+// JWT_LEEWAY (a Go duration) and JWT_ALGORITHMS (comma separated) settings.
+func jwtValidationFromEnv() (auth.Validation, error) {
+	v := auth.DefaultValidation
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		v.Issuer = issuer
+	}
+	v.Audience = os.Getenv("JWT_AUDIENCE")
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		d, err := time.ParseDuration(leeway)
+		if err != nil || d < 0 {
+			return v, fmt.Errorf("JWT_LEEWAY must be a non-negative duration, got %q", leeway)
+		}
+		v.Leeway = d
+	}
+	if algs := os.Getenv("JWT_ALGORITHMS"); algs != "" {
+		for _, alg := range strings.Split(algs, ",") {
+			v.Algorithms = append(v.Algorithms, strings.TrimSpace(alg))
+		}
+	}
+	return v, nil
+}
+
+// passwordParamsFromEnv starts from argon2id.DefaultParams and applies the
+// optional ARGON2_MEMORY (KiB), ARGON2_ITERATIONS and ARGON2_PARALLELISM
+// settings. go run ./cmd/tune-argon2 suggests values for this machine.
+func passwordParamsFromEnv() (argon2id.Params, error) {
+	params := *argon2id.DefaultParams
+	settings := []struct {
+		name string
+		dst  func(uint64)
+		bits int
+	}{
+		{"ARGON2_MEMORY", func(v uint64) { params.Memory = uint32(v) }, 32},
+		{"ARGON2_ITERATIONS", func(v uint64) { params.Iterations = uint32(v) }, 32},
+		{"ARGON2_PARALLELISM", func(v uint64) { params.Parallelism = uint8(v) }, 8},
+	}
+	for _, s := range settings {
+		raw := os.Getenv(s.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(raw, 10, s.bits)
+		if err != nil {
+			return params, fmt.Errorf("%s must be a positive integer, got %q", s.name, raw)
+		}
+		s.dst(v)
+	}
+	return params, nil
+}
+
+// passwordPolicyFromEnv starts from passwordpolicy.DefaultPolicy and applies
+// the optional PASSWORD_MIN_LENGTH, PASSWORD_MIN_STRENGTH (bits) and
+// BREACHED_PASSWORDS_DIR settings.
+func passwordPolicyFromEnv() (passwordpolicy.Policy, error) {
+	policy := passwordpolicy.DefaultPolicy
+	if raw := os.Getenv("PASSWORD_MIN_LENGTH"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > policy.MaxLength {
+			return policy, fmt.Errorf("PASSWORD_MIN_LENGTH must be between 1 and %d, got %q", policy.MaxLength, raw)
+		}
+		policy.MinLength = n
+	}
+	if raw := os.Getenv("PASSWORD_MIN_STRENGTH"); raw != "" {
+		bits, err := strconv.ParseFloat(raw, 64)
+		if err != nil || bits < 0 {
+			return policy, fmt.Errorf("PASSWORD_MIN_STRENGTH must be a non-negative number of bits, got %q", raw)
+		}
+		policy.MinStrength = bits
+	}
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return policy, fmt.Errorf("BREACHED_PASSWORDS_DIR %q is not a directory", dir)
+		}
+		policy.Breached = passwordpolicy.PrefixDir{Dir: dir}
+	}
+	return policy, nil
+}
+
+// billingProvidersFromEnv sets up the payment providers whose webhooks are
+// accepted; at least one is required.
+//
+// Polka is enabled by POLKA_WEBHOOK_SECRET, in which case it signs its
+// deliveries, or else by POLKA_KEY. The Stripe-style provider is enabled by
+// BILLING_WEBHOOK_SECRET, and named by BILLING_WEBHOOK_PROVIDER ("stripe"
+// unless set), which is also where it's mounted: /api/billing/<name>/webhooks.
+// BILLING_WEBHOOK_HEADER overrides the header carrying its signature.
+func billingProvidersFromEnv() (map[string]billing.Provider, error) {
+	providers := map[string]billing.Provider{}
+
+	polkaSignatures, err := webhookVerifierFromEnv("POLKA_WEBHOOK")
+	if err != nil {
+		return nil, err
+	}
+	if polkaKey := os.Getenv("POLKA_KEY"); polkaSignatures != nil || polkaKey != "" {
+		polka := billing.Polka{APIKey: polkaKey, Signatures: polkaSignatures}
+		providers[polka.Name()] = polka
+	}
+
+	signatures, err := webhookVerifierFromEnv("BILLING_WEBHOOK")
+	if err != nil {
+		return nil, err
+	}
+	if signatures != nil {
+		provider := billing.SignedJSON{
+			ProviderName: os.Getenv("BILLING_WEBHOOK_PROVIDER"),
+			Header:       os.Getenv("BILLING_WEBHOOK_HEADER"),
+			Signatures:   signatures,
+		}
+		if provider.ProviderName == "" {
+			provider.ProviderName = "stripe"
+		}
+		if _, taken := providers[provider.ProviderName]; taken {
+			return nil, fmt.Errorf("BILLING_WEBHOOK_PROVIDER %q is already taken", provider.ProviderName)
+		}
+		providers[provider.ProviderName] = provider
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("set POLKA_KEY, POLKA_WEBHOOK_SECRET or BILLING_WEBHOOK_SECRET")
+	}
+	return providers, nil
+}
+
+// webhookVerifierFromEnv reads <prefix>_SECRET, plus <prefix>_SECRET_PREVIOUS
+// while rotating it and an optional <prefix>_TOLERANCE. It returns nil
+// when there's no secret.
+func webhookVerifierFromEnv(prefix string) (*webhook.Verifier, error) {
+	secret := os.Getenv(prefix + "_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+	v, err := webhook.NewVerifier(secret, os.Getenv(prefix+"_SECRET_PREVIOUS"))
+	if err != nil {
+		return nil, err
+	}
+	if raw := os.Getenv(prefix + "_TOLERANCE"); raw != "" {
+		v.Tolerance, err = time.ParseDuration(raw)
+		if err != nil || v.Tolerance <= 0 {
+			return nil, fmt.Errorf("%s_TOLERANCE must be a positive duration, got %q", prefix, raw)
+		}
+	}
+	return v, nil
+}
+
+// mailerFromEnv sends through SMTP_ADDR when it's set, otherwise writes
+// emails to MAIL_DIR, otherwise logs them.
+func mailerFromEnv() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@localhost>"
+	}
+
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return mail.SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mail.FileMailer{Dir: dir, From: from}
+	}
+	log.Print("SMTP_ADDR and MAIL_DIR aren't set, emails will be logged")
+	return mail.LogMailer{}
+}
@@ -0,0 +1,58 @@
+-- name: AddChirpMention :exec
+INSERT INTO
+  chirp_mentions (chirp_id, user_id, handle, created_at)
+VALUES
+  ($1, $2, $3, NOW())
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: ClearChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE
+  chirp_id = $1;
+
+-- name: ListChirpMentions :many
+SELECT
+  *
+FROM
+  chirp_mentions
+WHERE
+  chirp_id = ANY (sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ListMentionChirps :many
+SELECT
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.arg('user_id')
+  ) AS liked_by_me
+FROM
+  chirps
+  INNER JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE
+  chirp_mentions.user_id = sqlc.arg('user_id')
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  sqlc.arg('page_size');
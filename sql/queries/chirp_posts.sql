@@ -0,0 +1,32 @@
+-- name: LockChirpPosts :exec
+-- Holds the user's posting lock until the transaction ends, so requests
+-- posting at once are counted one after the other.
+SELECT
+  pg_advisory_xact_lock(
+    hashtextextended('chirp_posts:' || sqlc.arg('user_id')::uuid::text, 0)
+  );
+
+-- name: PruneChirpPosts :exec
+-- Drops the user's posts from before since, which no rate limit looks at.
+DELETE FROM chirp_posts
+WHERE
+  user_id = sqlc.arg('user_id')
+  AND posted_at <= sqlc.arg('since');
+
+-- name: GetRecentChirpStats :one
+-- How many chirps the user has posted since, and when the first of them
+-- was (NOW() if none), for the posting rate limit. Deleted chirps count.
+SELECT
+  COUNT(*) AS chirp_count,
+  COALESCE(MIN(posted_at), NOW())::timestamp AS oldest
+FROM
+  chirp_posts
+WHERE
+  user_id = sqlc.arg('user_id')
+  AND posted_at > sqlc.arg('since');
+
+-- name: RecordChirpPost :exec
+INSERT INTO
+  chirp_posts (user_id)
+VALUES
+  ($1);
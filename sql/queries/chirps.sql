@@ -1,34 +1,426 @@
 -- name: CreateChirp :one
 INSERT INTO
-  chirps (id, created_at, updated_at, body, user_id)
+  chirps (id, created_at, updated_at, body, user_id, parent_id)
 VALUES
-  ($1, NOW(), NOW(), $2, $3) RETURNING *;
+  ($1, NOW(), NOW(), $2, $3, $4) RETURNING *;
 
--- name: GetAllChirps :many
+-- name: GetChirpById :one
 SELECT
   *
 FROM
   chirps
-ORDER BY
-  created_at ASC;
+WHERE
+  id = $1;
 
--- name: GetChirpById :one
+-- name: GetChirpByIdForUpdate :one
+-- Locks the chirp so no reply can be added to it until it's been deleted
+-- or tombstoned.
 SELECT
   *
 FROM
   chirps
 WHERE
-  id = $1;
+  id = $1 FOR UPDATE;
+
+-- name: GetChirpByIdForShare :one
+-- Locks the chirp against being deleted while a reply to it is added.
+SELECT
+  *
+FROM
+  chirps
+WHERE
+  id = $1 FOR SHARE;
 
 -- name: DeleteChirpById :exec
 DELETE FROM chirps
 WHERE
   id = $1;
 
--- name: GetChirpByUserId :many
+-- name: ListChirpsAsc :many
 SELECT
-  *
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me
+FROM
+  chirps
+WHERE
+  deleted_at IS NULL
+  AND (
+    sqlc.narg('author_id')::uuid IS NULL
+    OR user_id = sqlc.narg('author_id')
+  )
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  created_at ASC,
+  id ASC
+LIMIT
+  sqlc.arg('page_size');
+
+-- name: ListChirpsDesc :many
+SELECT
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me
+FROM
+  chirps
+WHERE
+  deleted_at IS NULL
+  AND (
+    sqlc.narg('author_id')::uuid IS NULL
+    OR user_id = sqlc.narg('author_id')
+  )
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  created_at DESC,
+  id DESC
+LIMIT
+  sqlc.arg('page_size');
+
+-- name: UpdateChirpBody :one
+-- Archives the current body into chirp_revisions in the same statement,
+-- so a chirp can never be edited without leaving a revision behind.
+WITH
+  previous AS (
+    INSERT INTO
+      chirp_revisions (id, created_at, chirp_id, body)
+    SELECT
+      sqlc.arg('revision_id')::uuid,
+      NOW(),
+      chirps.id,
+      chirps.body
+    FROM
+      chirps
+    WHERE
+      chirps.id = sqlc.arg('id')
+  )
+UPDATE chirps
+SET
+  body = sqlc.arg('body'),
+  updated_at = NOW()
+WHERE
+  chirps.id = sqlc.arg('id') RETURNING *;
+
+-- name: ChirpHasReplies :one
+SELECT
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirps
+    WHERE
+      parent_id = sqlc.arg('id')::uuid
+  );
+
+-- name: TombstoneChirp :exec
+-- Keeps the row so replies still have a parent, but drops everything the
+-- author wrote, including the edit history, hashtags and mentions.
+WITH
+  purged_revisions AS (
+    DELETE FROM chirp_revisions
+    WHERE
+      chirp_id = $1
+  ),
+  purged_hashtags AS (
+    DELETE FROM chirp_hashtags
+    WHERE
+      chirp_id = $1
+  ),
+  purged_mentions AS (
+    DELETE FROM chirp_mentions
+    WHERE
+      chirp_id = $1
+  )
+UPDATE chirps
+SET
+  body = '',
+  updated_at = NOW(),
+  deleted_at = NOW()
+WHERE
+  id = $1;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE
+  ancestors AS (
+    SELECT
+      parent.id,
+      parent.created_at,
+      parent.updated_at,
+      parent.body,
+      parent.user_id,
+      parent.parent_id,
+      parent.deleted_at,
+      1 AS depth
+    FROM
+      chirps child
+      INNER JOIN chirps parent ON parent.id = child.parent_id
+    WHERE
+      child.id = sqlc.arg('id')
+    UNION ALL
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      ancestors.depth + 1
+    FROM
+      chirps
+      INNER JOIN ancestors ON chirps.id = ancestors.parent_id
+  )
+SELECT
+  id,
+  created_at,
+  updated_at,
+  body,
+  user_id,
+  parent_id,
+  deleted_at,
+  depth,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = ancestors.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = ancestors.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me
+FROM
+  ancestors
+ORDER BY
+  depth DESC;
+
+-- name: GetChirpReplies :many
+WITH RECURSIVE
+  replies AS (
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      1 AS depth
+    FROM
+      chirps
+    WHERE
+      chirps.parent_id = sqlc.arg('id')
+    UNION ALL
+    SELECT
+      chirps.id,
+      chirps.created_at,
+      chirps.updated_at,
+      chirps.body,
+      chirps.user_id,
+      chirps.parent_id,
+      chirps.deleted_at,
+      replies.depth + 1
+    FROM
+      chirps
+      INNER JOIN replies ON chirps.parent_id = replies.id
+    WHERE
+      replies.depth < sqlc.arg('max_depth')::int
+  )
+SELECT
+  id,
+  created_at,
+  updated_at,
+  body,
+  user_id,
+  parent_id,
+  deleted_at,
+  depth,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = replies.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = replies.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me
+FROM
+  replies
+ORDER BY
+  created_at ASC,
+  id ASC;
+
+-- name: ListTimeline :many
+SELECT
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.arg('follower_id')
+  ) AS liked_by_me
 FROM
   chirps
+  INNER JOIN follows ON follows.followee_id = chirps.user_id
 WHERE
-  user_id = $1;
+  follows.follower_id = sqlc.arg('follower_id')
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  sqlc.arg('page_size');
+
+-- name: SearchChirps :many
+-- Ranked full-text search. Pages are keyed on (rank, created_at, id), so the
+-- cursor rank has to be the exact real ts_rank returned for the previous page.
+-- The snippet is safe HTML: the body is escaped before matches are wrapped
+-- in <mark> tags.
+SELECT
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me,
+  ts_rank(chirps.search_vector, query)::real AS rank,
+  ts_headline(
+    'english',
+    replace(
+      replace(
+        replace(
+          replace(replace(chirps.body, '&', '&amp;'), '<', '&lt;'),
+          '>',
+          '&gt;'
+        ),
+        '"',
+        '&quot;'
+      ),
+      '''',
+      '&#39;'
+    ),
+    query,
+    'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+  )::text AS snippet
+FROM
+  chirps,
+  websearch_to_tsquery('english', sqlc.arg('query')) AS query
+WHERE
+  chirps.search_vector @@ query
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg('author_id')::uuid IS NULL
+    OR chirps.user_id = sqlc.narg('author_id')
+  )
+  AND (
+    sqlc.narg('since')::timestamp IS NULL
+    OR chirps.created_at >= sqlc.narg('since')::timestamp
+  )
+  AND (
+    sqlc.narg('until')::timestamp IS NULL
+    OR chirps.created_at < sqlc.narg('until')::timestamp
+  )
+  AND (
+    sqlc.narg('cursor_rank')::real IS NULL
+    OR (
+      ts_rank(chirps.search_vector, query)::real,
+      chirps.created_at,
+      chirps.id
+    ) < (
+      sqlc.narg('cursor_rank')::real,
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  rank DESC,
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  sqlc.arg('page_size');
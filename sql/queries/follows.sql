@@ -0,0 +1,12 @@
+-- name: FollowUser :exec
+INSERT INTO
+  follows (follower_id, followee_id, created_at)
+VALUES
+  ($1, $2, NOW())
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE
+  follower_id = $1
+  AND followee_id = $2;
@@ -0,0 +1,83 @@
+-- name: UpsertHashtag :one
+INSERT INTO
+  hashtags (id, created_at, tag)
+VALUES
+  ($1, NOW(), $2)
+ON CONFLICT (tag) DO UPDATE
+SET
+  tag = EXCLUDED.tag RETURNING *;
+
+-- name: AddChirpHashtag :exec
+INSERT INTO
+  chirp_hashtags (chirp_id, hashtag_id, created_at)
+VALUES
+  ($1, $2, NOW())
+ON CONFLICT (chirp_id, hashtag_id) DO NOTHING;
+
+-- name: PruneChirpHashtags :exec
+-- Drops the chirp's tags that aren't in keep. Tags that stay keep their
+-- original created_at, so editing a chirp doesn't bump them in trending.
+DELETE FROM chirp_hashtags USING hashtags
+WHERE
+  chirp_hashtags.hashtag_id = hashtags.id
+  AND chirp_hashtags.chirp_id = sqlc.arg('chirp_id')
+  AND NOT (hashtags.tag = ANY (sqlc.arg('keep')::text[]));
+
+-- name: ListHashtagChirps :many
+SELECT
+  sqlc.embed(chirps),
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+  ) AS like_count,
+  EXISTS (
+    SELECT
+      1
+    FROM
+      chirp_likes
+    WHERE
+      chirp_likes.chirp_id = chirps.id
+      AND chirp_likes.user_id = sqlc.narg('viewer_id')::uuid
+  ) AS liked_by_me
+FROM
+  chirps
+  INNER JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+  INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE
+  hashtags.tag = sqlc.arg('tag')
+  AND chirps.deleted_at IS NULL
+  AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (
+      sqlc.narg('cursor_created_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  chirps.created_at DESC,
+  chirps.id DESC
+LIMIT
+  sqlc.arg('page_size');
+
+-- name: TrendingHashtags :many
+SELECT
+  hashtags.tag,
+  COUNT(*) AS uses
+FROM
+  chirp_hashtags
+  INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE
+  chirp_hashtags.created_at >= NOW() - (
+    sqlc.arg('window_seconds')::int * INTERVAL '1 second'
+  )
+GROUP BY
+  hashtags.tag
+ORDER BY
+  uses DESC,
+  hashtags.tag ASC
+LIMIT
+  sqlc.arg('max_tags');
@@ -0,0 +1,11 @@
+-- name: CreateLoginAttempt :exec
+INSERT INTO
+  login_attempts (email, user_id, ip_address, user_agent, outcome)
+VALUES
+  ($1, $2, $3, $4, $5);
+
+-- name: PruneLoginAttempts :execrows
+-- Drops attempts from before the retention window.
+DELETE FROM login_attempts
+WHERE
+  created_at < sqlc.arg('before');
@@ -0,0 +1,21 @@
+-- name: CreateLoginChallenge :exec
+INSERT INTO
+  login_challenges (token, user_id, expires_at)
+VALUES
+  ($1, $2, $3);
+
+-- name: AttemptLoginChallenge :one
+-- Counts an attempt against a live challenge. No rows means it expired,
+-- was used up or never existed.
+UPDATE login_challenges
+SET
+  attempts = attempts + 1
+WHERE
+  token = sqlc.arg('token')
+  AND expires_at > NOW()
+  AND attempts < sqlc.arg('max_attempts')::INT RETURNING *;
+
+-- name: DeleteLoginChallenge :execrows
+DELETE FROM login_challenges
+WHERE
+  token = $1;
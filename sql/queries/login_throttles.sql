@@ -0,0 +1,45 @@
+-- name: GetLoginThrottle :one
+SELECT
+  *
+FROM
+  login_throttles
+WHERE
+  key = $1;
+
+-- name: InsertLoginThrottle :execrows
+-- No rows means another attempt created the key first.
+INSERT INTO
+  login_throttles (key, failures, last_failure_at)
+VALUES
+  ($1, $2, $3)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: SwapLoginThrottle :execrows
+-- Only changes the key if it's still in the state the caller last read, so
+-- concurrent attempts can't both count against the same old state. No rows
+-- means it has moved on.
+UPDATE login_throttles
+SET
+  failures = sqlc.arg('failures'),
+  last_failure_at = sqlc.arg('last_failure_at')
+WHERE
+  key = sqlc.arg('key')
+  AND failures = sqlc.arg('prev_failures')
+  AND last_failure_at = sqlc.arg('prev_last_failure_at');
+
+-- name: ReleaseLoginThrottle :exec
+UPDATE login_throttles
+SET
+  failures = GREATEST(failures - 1, 0)
+WHERE
+  key = $1;
+
+-- name: ResetLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE
+  key = $1;
+
+-- name: PruneLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE
+  last_failure_at < $1;
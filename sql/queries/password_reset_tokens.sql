@@ -0,0 +1,24 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO
+  password_reset_tokens (token_hash, user_id, expires_at)
+VALUES
+  ($1, $2, $3);
+
+-- name: ConsumePasswordResetToken :one
+-- Marks a live token used and returns it. No rows means it was already
+-- used, expired or never existed.
+UPDATE password_reset_tokens
+SET
+  used_at = NOW()
+WHERE
+  token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > NOW() RETURNING *;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET
+  used_at = NOW()
+WHERE
+  user_id = $1
+  AND used_at IS NULL;
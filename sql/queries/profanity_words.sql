@@ -0,0 +1,19 @@
+-- name: ListProfanityWords :many
+SELECT
+  word
+FROM
+  profanity_words
+ORDER BY
+  word ASC;
+
+-- name: AddProfanityWord :exec
+INSERT INTO
+  profanity_words (word, created_at)
+VALUES
+  ($1, NOW())
+ON CONFLICT (word) DO NOTHING;
+
+-- name: DeleteProfanityWord :exec
+DELETE FROM profanity_words
+WHERE
+  word = $1;
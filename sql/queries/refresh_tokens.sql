@@ -6,10 +6,13 @@ INSERT INTO
     updated_at,
     user_id,
     expires_at,
-    revoked_at
+    revoked_at,
+    family_id,
+    user_agent,
+    ip_address
   )
 VALUES
-  ($1, NOW(), NOW(), $2, $3, $4) RETURNING *;
+  ($1, NOW(), NOW(), $2, $3, $4, $5, $6, $7) RETURNING *;
 
 -- name: GetRefreshToken :one
 SELECT
@@ -19,6 +22,16 @@ FROM
 WHERE
   token = $1;
 
+-- name: GetRefreshTokenForUpdate :one
+-- Locks the row so two refreshes racing with the same token can't both
+-- rotate it.
+SELECT
+  *
+FROM
+  refresh_tokens
+WHERE
+  token = $1 FOR UPDATE;
+
 -- name: UpdateRefreshToken :exec
 UPDATE refresh_tokens
 SET
@@ -26,3 +39,73 @@ SET
   revoked_at = NOW()
 WHERE
   token = $1;
+
+-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = NOW(),
+  replaced_by = sqlc.arg('replaced_by')
+WHERE
+  token = sqlc.arg('token');
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = COALESCE(revoked_at, NOW())
+WHERE
+  family_id = $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = COALESCE(revoked_at, NOW())
+WHERE
+  user_id = $1;
+
+-- name: ListSessions :many
+-- A session is a token family; its live token was issued at the last login
+-- or refresh, which is when the session was last used.
+SELECT
+  live.family_id,
+  (
+    SELECT
+      MIN(first.created_at)
+    FROM
+      refresh_tokens first
+    WHERE
+      first.family_id = live.family_id
+  )::TIMESTAMP AS created_at,
+  live.created_at AS last_used_at,
+  live.expires_at,
+  live.user_agent,
+  live.ip_address
+FROM
+  refresh_tokens live
+WHERE
+  live.user_id = $1
+  AND live.revoked_at IS NULL
+  AND live.expires_at > NOW()
+ORDER BY
+  live.created_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = COALESCE(revoked_at, NOW())
+WHERE
+  family_id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET
+  updated_at = NOW(),
+  revoked_at = COALESCE(revoked_at, NOW())
+WHERE
+  user_id = $1
+  AND family_id <> $2;
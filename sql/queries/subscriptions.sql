@@ -0,0 +1,158 @@
+-- name: GetSubscription :one
+SELECT
+  *
+FROM
+  subscriptions
+WHERE
+  user_id = $1;
+
+-- name: ActivateSubscription :one
+-- Starts or renews the user's subscription, unless it has already been
+-- changed by an event after event_at. A NULL plan keeps the current one. No
+-- rows means there's no such user, or the event is out of date.
+INSERT INTO
+  subscriptions (
+    user_id,
+    plan,
+    status,
+    current_period_end,
+    grace_period_end,
+    last_event_id,
+    last_event_at
+  )
+SELECT
+  users.id,
+  COALESCE(sqlc.narg('plan'), sqlc.arg('default_plan')),
+  'active',
+  sqlc.arg('current_period_end'),
+  sqlc.arg('grace_period_end'),
+  sqlc.arg('event_id'),
+  sqlc.arg('event_at')
+FROM
+  users
+WHERE
+  users.id = sqlc.arg('user_id')
+ON CONFLICT (user_id) DO UPDATE
+SET
+  plan = COALESCE(sqlc.narg('plan'), subscriptions.plan),
+  status = 'active',
+  current_period_end = EXCLUDED.current_period_end,
+  grace_period_end = EXCLUDED.grace_period_end,
+  last_event_id = EXCLUDED.last_event_id,
+  last_event_at = EXCLUDED.last_event_at,
+  updated_at = NOW()
+WHERE
+  subscriptions.last_event_at IS NULL
+  OR subscriptions.last_event_at <= EXCLUDED.last_event_at RETURNING *;
+
+-- name: RenewSubscription :one
+-- Renews the subscription, or starts one if there's none. The new period
+-- ends period_seconds from now, or extend_seconds after the current one if
+-- that's later: with extend_seconds set to the period, renewing early adds
+-- a period to the one already paid for, and with 0 a repeat of the same
+-- renewal changes nothing. No rows means there's no such user, or event_id
+-- has already been applied, or the subscription has been changed by an
+-- event after event_at.
+INSERT INTO
+  subscriptions (
+    user_id,
+    plan,
+    status,
+    current_period_end,
+    grace_period_end,
+    last_event_id,
+    last_event_at
+  )
+SELECT
+  users.id,
+  COALESCE(sqlc.narg('plan'), sqlc.arg('default_plan')),
+  'active',
+  NOW() + make_interval(secs => sqlc.arg('period_seconds')::float8),
+  NOW() + make_interval(
+    secs => sqlc.arg('period_seconds')::float8 + sqlc.arg('grace_seconds')::float8
+  ),
+  sqlc.arg('event_id'),
+  sqlc.arg('event_at')
+FROM
+  users
+WHERE
+  users.id = sqlc.arg('user_id')
+ON CONFLICT (user_id) DO UPDATE
+SET
+  plan = COALESCE(sqlc.narg('plan'), subscriptions.plan),
+  status = 'active',
+  current_period_end = GREATEST(
+    subscriptions.current_period_end + make_interval(secs => sqlc.arg('extend_seconds')::float8),
+    EXCLUDED.current_period_end
+  ),
+  grace_period_end = GREATEST(
+    subscriptions.current_period_end + make_interval(
+      secs => sqlc.arg('extend_seconds')::float8 + sqlc.arg('grace_seconds')::float8
+    ),
+    EXCLUDED.grace_period_end
+  ),
+  last_event_id = EXCLUDED.last_event_id,
+  last_event_at = EXCLUDED.last_event_at,
+  updated_at = NOW()
+WHERE
+  subscriptions.last_event_id IS DISTINCT FROM EXCLUDED.last_event_id
+  AND (
+    subscriptions.last_event_at IS NULL
+    OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+  ) RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+-- No rows means the user has no live subscription to fall behind on, or
+-- it has been changed by an event after event_at.
+UPDATE subscriptions
+SET
+  status = 'past_due',
+  last_event_id = sqlc.arg('event_id'),
+  last_event_at = sqlc.arg('event_at'),
+  updated_at = NOW()
+WHERE
+  user_id = sqlc.arg('user_id')
+  AND status IN ('active', 'past_due')
+  AND (
+    last_event_at IS NULL
+    OR last_event_at <= sqlc.arg('event_at')
+  ) RETURNING *;
+
+-- name: CancelSubscription :one
+-- Red ends right away. No rows means there was nothing to cancel, or the
+-- subscription has been changed by an event after event_at.
+UPDATE subscriptions
+SET
+  status = 'canceled',
+  grace_period_end = LEAST(grace_period_end, NOW()),
+  last_event_id = sqlc.arg('event_id'),
+  last_event_at = sqlc.arg('event_at'),
+  updated_at = NOW()
+WHERE
+  user_id = sqlc.arg('user_id')
+  AND status IN ('active', 'past_due')
+  AND (
+    last_event_at IS NULL
+    OR last_event_at <= sqlc.arg('event_at')
+  ) RETURNING *;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET
+  status = 'expired',
+  updated_at = NOW()
+WHERE
+  status IN ('active', 'past_due')
+  AND grace_period_end <= NOW() RETURNING user_id;
+
+-- name: ListChirpyRedUsers :many
+-- Which of user_ids are Red right now. This is the one place that decides;
+-- everything else asks the entitlements service.
+SELECT
+  user_id
+FROM
+  subscriptions
+WHERE
+  user_id = ANY (sqlc.arg('user_ids')::uuid[])
+  AND status IN ('active', 'past_due')
+  AND grace_period_end > NOW();
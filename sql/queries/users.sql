@@ -5,10 +5,11 @@ INSERT INTO
     created_at,
     updated_at,
     email,
-    hashed_password
+    hashed_password,
+    handle
   )
 VALUES
-  ($1, NOW(), NOW(), $2, $3) RETURNING *;
+  ($1, NOW(), NOW(), $2, $3, $4) RETURNING *;
 
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
@@ -31,19 +32,19 @@ WHERE
   refresh_tokens.token = $1;
 
 -- name: UpdateUser :one
+-- Only the fields passed as non-NULL change, so callers can update the
+-- profile without resending the password. Email changes go through
+-- SetPendingEmail and ConfirmEmail instead.
 UPDATE users
 SET
-  email = $1,
-  hashed_password = $2
+  hashed_password = COALESCE(sqlc.narg('hashed_password'), hashed_password),
+  handle = COALESCE(sqlc.narg('handle'), handle),
+  display_name = COALESCE(sqlc.narg('display_name'), display_name),
+  bio = COALESCE(sqlc.narg('bio'), bio),
+  avatar_url = COALESCE(sqlc.narg('avatar_url'), avatar_url),
+  updated_at = NOW()
 WHERE
-  id = $3 RETURNING *;
-
--- name: UpgradeUser :one
-UPDATE users
-SET
-  is_chirpy_red = true
-WHERE
-  id = $1 RETURNING *;
+  id = sqlc.arg('id') RETURNING *;
 
 -- name: FindUserById :one
 SELECT
@@ -52,3 +53,129 @@ FROM
   users
 WHERE
   id = $1;
+
+-- name: GetUsersByHandles :many
+SELECT
+  id,
+  handle
+FROM
+  users
+WHERE
+  handle = ANY (sqlc.arg('handles')::text[]);
+
+-- name: GetUserProfile :one
+-- Looks a user up by ID or by handle, whichever is given.
+SELECT
+  users.id,
+  users.created_at,
+  users.handle,
+  users.display_name,
+  users.bio,
+  users.avatar_url,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      follows
+    WHERE
+      follows.followee_id = users.id
+  ) AS follower_count,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      follows
+    WHERE
+      follows.follower_id = users.id
+  ) AS following_count,
+  (
+    SELECT
+      COUNT(*)
+    FROM
+      chirps
+    WHERE
+      chirps.user_id = users.id
+      AND chirps.deleted_at IS NULL
+  ) AS chirp_count
+FROM
+  users
+WHERE
+  users.id = sqlc.narg('id')
+  OR users.handle = sqlc.narg('handle');
+
+-- name: StartTOTPEnrollment :execrows
+-- Replaces any unconfirmed secret; does nothing once 2FA is enabled.
+UPDATE users
+SET
+  totp_secret = $2,
+  updated_at = NOW()
+WHERE
+  id = $1
+  AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET
+  totp_enabled_at = NOW(),
+  totp_last_step = $2,
+  updated_at = NOW()
+WHERE
+  id = $1;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET
+  totp_secret = NULL,
+  totp_enabled_at = NULL,
+  totp_last_step = 0,
+  updated_at = NOW()
+WHERE
+  id = $1;
+
+-- name: UseTOTPStep :execrows
+-- Records the step of an accepted code. No rows means that step (or a later
+-- one) was already used.
+UPDATE users
+SET
+  totp_last_step = $2
+WHERE
+  id = $1
+  AND totp_last_step < $2;
+
+-- name: SetPendingEmail :one
+-- Passing the current email cancels a pending change.
+UPDATE users
+SET
+  pending_email = NULLIF(sqlc.arg('pending_email')::TEXT, email),
+  updated_at = NOW()
+WHERE
+  id = sqlc.arg('id') RETURNING *;
+
+-- name: ConfirmEmail :one
+-- Marks email verified for the user, switching to it first if it's their
+-- pending email. No rows means it's neither their email nor pending.
+UPDATE users
+SET
+  email = sqlc.arg('email'),
+  pending_email = CASE
+    WHEN pending_email = sqlc.arg('email') THEN NULL
+    ELSE pending_email
+  END,
+  email_verified_at = NOW(),
+  updated_at = NOW()
+WHERE
+  id = sqlc.arg('id')
+  AND (
+    email = sqlc.arg('email')
+    OR pending_email = sqlc.arg('email')
+  ) RETURNING *;
+
+-- name: RehashPassword :exec
+-- Swaps in a stronger hash of the same password. It leaves updated_at
+-- alone, and does nothing if the password changed since old_hash was read.
+UPDATE users
+SET
+  hashed_password = sqlc.arg('new_hash')
+WHERE
+  id = sqlc.arg('id')
+  AND hashed_password = sqlc.arg('old_hash');
@@ -0,0 +1,97 @@
+-- name: CreateWebhookEvent :one
+-- No rows means the provider already sent this event.
+INSERT INTO
+  webhook_events (
+    id,
+    provider,
+    event_id,
+    event_type,
+    user_id,
+    payload
+  )
+VALUES
+  ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, event_id) DO NOTHING RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT
+  *
+FROM
+  webhook_events
+WHERE
+  id = $1;
+
+-- name: GetWebhookEventByEventID :one
+SELECT
+  *
+FROM
+  webhook_events
+WHERE
+  provider = $1
+  AND event_id = $2;
+
+-- name: ClaimWebhookEvent :one
+-- Marks the event as being acted on. A claim made before stale_before is
+-- taken to have been abandoned and can be taken over. No rows means it's
+-- already been handled, or another request is handling it right now.
+UPDATE webhook_events
+SET
+  status = 'processing',
+  claimed_at = NOW()
+WHERE
+  id = sqlc.arg('id')
+  AND (
+    status IN ('pending', 'failed')
+    OR (
+      status = 'processing'
+      AND claimed_at < sqlc.arg('stale_before')::timestamp
+    )
+  ) RETURNING *;
+
+-- name: FinishWebhookEvent :one
+-- Records the outcome of the claim made at claimed_at. No rows means the
+-- claim went stale and was taken over.
+UPDATE webhook_events
+SET
+  status = sqlc.arg('status'),
+  last_error = sqlc.arg('last_error'),
+  attempts = attempts + 1,
+  processed_at = CASE
+    WHEN sqlc.arg('status') IN ('processed', 'ignored') THEN NOW()
+  END
+WHERE
+  id = sqlc.arg('id')
+  AND status = 'processing'
+  AND claimed_at = sqlc.arg('claimed_at') RETURNING *;
+
+-- name: ListWebhookEvents :many
+-- Newest first, optionally only one provider, status or user.
+SELECT
+  *
+FROM
+  webhook_events
+WHERE
+  (
+    sqlc.narg('provider')::TEXT IS NULL
+    OR provider = sqlc.narg('provider')
+  )
+  AND (
+    sqlc.narg('status')::TEXT IS NULL
+    OR status = sqlc.narg('status')
+  )
+  AND (
+    sqlc.narg('user_id')::UUID IS NULL
+    OR user_id = sqlc.narg('user_id')
+  )
+  AND (
+    sqlc.narg('cursor_received_at')::timestamp IS NULL
+    OR (received_at, id) < (
+      sqlc.narg('cursor_received_at')::timestamp,
+      sqlc.narg('cursor_id')::uuid
+    )
+  )
+ORDER BY
+  received_at DESC,
+  id DESC
+LIMIT
+  sqlc.arg('page_size');
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE profanity_words (
+  word TEXT PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO
+  profanity_words (word, created_at)
+VALUES
+  ('kerfuffle', NOW()),
+  ('sharbert', NOW()),
+  ('fornax', NOW());
+
+-- +goose Down
+DROP TABLE profanity_words;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Every login starts a family; each /api/refresh replaces the presented
+-- token with a new one in the same family and records which one replaced it.
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID,
+ADD COLUMN replaced_by TEXT;
+
+UPDATE refresh_tokens
+SET
+  family_id = gen_random_uuid ();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id
+SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by,
+DROP COLUMN family_id;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Where each token was issued, so users can recognise their sessions.
+-- Rotation copies the latest values onto the replacement token.
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP COLUMN ip_address,
+DROP COLUMN user_agent;
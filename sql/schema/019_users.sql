@@ -0,0 +1,14 @@
+-- +goose Up
+-- totp_secret is set when enrollment starts and only counts once
+-- totp_enabled_at is set. totp_last_step is the time step of the last code
+-- accepted, so a code can't be replayed.
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT,
+ADD COLUMN totp_enabled_at TIMESTAMP,
+ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN totp_last_step,
+DROP COLUMN totp_enabled_at,
+DROP COLUMN totp_secret;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- A login that passed the password check and still needs a second factor.
+CREATE TABLE login_challenges (
+  token TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  attempts INT NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE login_challenges;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- pending_email is an address the user asked to switch to; it replaces
+-- email only once a link sent to it has been opened.
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP,
+ADD COLUMN pending_email TEXT;
+
+-- Accounts from before verification existed count as verified, so turning
+-- on REQUIRE_VERIFIED_EMAIL doesn't stop them all posting.
+UPDATE users
+SET
+  email_verified_at = NOW();
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN pending_email,
+DROP COLUMN email_verified_at;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Audit log of every login attempt, kept for a limited time. user_id is
+-- NULL when the email doesn't belong to anyone.
+CREATE TABLE login_attempts (
+  id BIGSERIAL PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  email TEXT NOT NULL,
+  user_id UUID REFERENCES users (id) ON DELETE SET NULL,
+  ip_address TEXT NOT NULL,
+  user_agent TEXT NOT NULL,
+  outcome TEXT NOT NULL
+);
+
+CREATE INDEX login_attempts_email_created_at_idx ON login_attempts (email, created_at);
+
+CREATE INDEX login_attempts_ip_address_created_at_idx ON login_attempts (ip_address, created_at);
+
+CREATE INDEX login_attempts_created_at_idx ON login_attempts (created_at);
+
+-- +goose Down
+DROP TABLE login_attempts;
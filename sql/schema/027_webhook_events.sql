@@ -0,0 +1,28 @@
+-- +goose Up
+-- Every webhook delivery we accepted, so retries can be recognised and
+-- anyone can see what a provider told us and what we did about it.
+-- status is 'pending', 'processing' (claimed by a request acting on it at
+-- claimed_at), 'processed', 'ignored' (an event we don't act on) or
+-- 'failed', with last_error saying why.
+CREATE TABLE webhook_events (
+  id UUID PRIMARY KEY,
+  provider TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  user_id UUID,
+  payload TEXT NOT NULL,
+  received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  last_error TEXT,
+  processed_at TIMESTAMP,
+  claimed_at TIMESTAMP,
+  UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at, id);
+
+CREATE INDEX webhook_events_user_id_idx ON webhook_events (user_id);
+
+-- +goose Down
+DROP TABLE webhook_events;
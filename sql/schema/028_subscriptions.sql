@@ -0,0 +1,62 @@
+-- +goose Up
+-- A user's Chirpy Red subscription. They're Red while status is 'active'
+-- or 'past_due' (a payment failed, but they're still in their grace
+-- period) and grace_period_end, which is current_period_end plus the grace
+-- period, hasn't passed. 'canceled' and 'expired' subscriptions are kept
+-- for their history. last_event_id is the webhook event that last changed
+-- the subscription, so the same one can't extend it twice, and
+-- last_event_at is when that event happened, so a late delivery of an
+-- older one can't undo it.
+CREATE TABLE subscriptions (
+  user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  plan TEXT NOT NULL,
+  status TEXT NOT NULL,
+  current_period_end TIMESTAMP NOT NULL,
+  grace_period_end TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_event_id UUID REFERENCES webhook_events (id) ON DELETE SET NULL,
+  last_event_at TIMESTAMP
+);
+
+CREATE INDEX subscriptions_status_grace_period_end_idx ON subscriptions (status, grace_period_end);
+
+-- Existing Red users never told us when they renew, so give them a month
+-- for the next renewal event to arrive.
+INSERT INTO
+  subscriptions (
+    user_id,
+    plan,
+    status,
+    current_period_end,
+    grace_period_end
+  )
+SELECT
+  id,
+  'red',
+  'active',
+  NOW() + INTERVAL '30 days',
+  NOW() + INTERVAL '33 days'
+FROM
+  users
+WHERE
+  is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users
+SET
+  is_chirpy_red = true
+FROM
+  subscriptions
+WHERE
+  subscriptions.user_id = users.id
+  AND subscriptions.status IN ('active', 'past_due')
+  AND subscriptions.grace_period_end > NOW();
+
+DROP TABLE subscriptions;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- When each user posted, for the posting rate limit. Unlike chirps, these
+-- stay when a chirp is deleted, so deleting doesn't give posts back.
+CREATE TABLE chirp_posts (
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  posted_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX chirp_posts_user_id_posted_at_idx ON chirp_posts (user_id, posted_at);
+
+INSERT INTO
+  chirp_posts (user_id, posted_at)
+SELECT
+  user_id,
+  created_at
+FROM
+  chirps
+WHERE
+  created_at > NOW() - INTERVAL '1 hour';
+
+-- +goose Down
+DROP TABLE chirp_posts;